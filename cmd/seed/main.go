@@ -53,7 +53,7 @@ func main() {
 	mockTournament := models.Tournament{
 		Name:   "Mock Blade Battle " + time.Now().Format("15:04"),
 		Date:   time.Now(),
-		Status: "Created",
+		Status: models.StatusCreated,
 	}
 
 	db.DB.Create(&mockTournament)
@@ -2,6 +2,7 @@ package db
 
 import (
 	"bbx_tournament/models"
+	"fmt"
 	"log"
 
 	"github.com/glebarez/sqlite"
@@ -16,19 +17,72 @@ func InitDB(dataSourceName string) {
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
+	if err := Migrate(DB); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
 
+	log.Println("Database connection established and migrated successfully.")
+}
+
+// Migrate brings the schema up to date and fills in what older rows are missing
+func Migrate(conn *gorm.DB) error {
 	// Auto Migrate the schema
-	err = DB.AutoMigrate(
+	err := conn.AutoMigrate(
 		&models.Participant{},
 		&models.Deck{},
 		&models.Beyblade{},
 		&models.Tournament{},
 		&models.TournamentParticipant{},
 		&models.Match{},
+		&models.MatchRound{},
+		&models.TournamentTransition{},
+		&models.TournamentEvent{},
 	)
 	if err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+		return err
 	}
 
-	log.Println("Database connection established and migrated successfully.")
+	// Tournaments created before rule sets existed play by the default rules
+	defaults := models.DefaultRuleSet()
+	err = conn.Model(&models.Tournament{}).
+		Where("rule_group_win_limit IS NULL OR rule_group_win_limit = 0").
+		Updates(map[string]interface{}{
+			"rule_spin_points":       defaults.SpinPoints,
+			"rule_over_points":       defaults.OverPoints,
+			"rule_burst_points":      defaults.BurstPoints,
+			"rule_out_points":        defaults.OutPoints,
+			"rule_xtreme_points":     defaults.XtremePoints,
+			"rule_group_win_limit":   defaults.GroupWinLimit,
+			"rule_bracket_win_limit": defaults.BracketWinLimit,
+			"rule_match_win_points":  defaults.MatchWinPoints,
+			"rule_draw_points":       defaults.DrawPoints,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("backfill tournament rules: %w", err)
+	}
+
+	// The penalty columns are added empty to tournaments from before penalties. Rows saved
+	// since always hold a value, so a 0 there is a rule set that switched penalties off.
+	for column, value := range map[string]int{
+		"rule_penalty_warnings": defaults.PenaltyWarnings,
+		"rule_penalty_points":   defaults.PenaltyPoints,
+	} {
+		err = conn.Model(&models.Tournament{}).Where(column+" IS NULL").Update(column, value).Error
+		if err != nil {
+			return fmt.Errorf("backfill penalty rules: %w", err)
+		}
+	}
+
+	// Rounds logged before combos were copied read them from the Beyblade rows, deleted or not
+	for _, side := range []string{"player1", "player2"} {
+		err = conn.Exec(fmt.Sprintf(`UPDATE match_rounds SET
+			%[1]s_blade = (SELECT blade FROM beyblades WHERE beyblades.id = match_rounds.%[1]s_beyblade_id),
+			%[1]s_ratchet = (SELECT ratchet FROM beyblades WHERE beyblades.id = match_rounds.%[1]s_beyblade_id),
+			%[1]s_bit = (SELECT bit FROM beyblades WHERE beyblades.id = match_rounds.%[1]s_beyblade_id)
+			WHERE %[1]s_beyblade_id IS NOT NULL AND (%[1]s_blade IS NULL OR %[1]s_blade = '')`, side)).Error
+		if err != nil {
+			return fmt.Errorf("backfill round combos: %w", err)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"bbx_tournament/db"
+	"bbx_tournament/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// BracketPlayer is one side of a bracket match
+type BracketPlayer struct {
+	ParticipantID uint   `json:"participant_id"` // 0 while waiting for the feeder match, or the empty side of a bye
+	Nickname      string `json:"nickname"`
+	Seed          int    `json:"seed"`
+	Score         int    `json:"score"`
+	Winner        bool   `json:"winner"`
+	FeederMatchID uint   `json:"feeder_match_id,omitempty"` // Match the player comes from, 0 for a seeded slot
+	FeederLoser   bool   `json:"feeder_loser,omitempty"`    // Comes as the feeder's loser
+}
+
+// BracketMatch is a node of the bracket tree. Matches of rounds not generated yet are listed
+// with a zero ID so the whole single-elimination shape can be drawn up front.
+type BracketMatch struct {
+	MatchID          uint             `json:"match_id"`
+	Round            int              `json:"round"`
+	Slot             int              `json:"slot"`
+	IsBye            bool             `json:"is_bye"`
+	Decided          bool             `json:"decided"`
+	Forfeit          string           `json:"forfeit,omitempty"`
+	Players          [2]BracketPlayer `json:"players"`
+	WinnerID         *uint            `json:"winner_id"`
+	NextMatchID      uint             `json:"next_match_id,omitempty"`       // Where the winner goes
+	LoserNextMatchID uint             `json:"loser_next_match_id,omitempty"` // Where the loser goes: losers bracket or match for third
+}
+
+// BracketRound is a column of the bracket
+type BracketRound struct {
+	Round   int            `json:"round"`
+	Name    string         `json:"name"` // Final, Semifinals, Winners Round 1...
+	Matches []BracketMatch `json:"matches"`
+}
+
+// BracketSection is one bracket of the tree: the main bracket, the match for third, or the
+// winners bracket, losers bracket and grand final of double elimination
+type BracketSection struct {
+	Phase  string         `json:"phase"`
+	Rounds []BracketRound `json:"rounds"`
+}
+
+// BracketTree is the elimination stage laid out for rendering
+type BracketTree struct {
+	TournamentID uint             `json:"tournament_id"`
+	Format       string           `json:"format"` // SingleElimination, DoubleElimination
+	Size         int              `json:"size"`   // Seeded slots, rounded up to a power of two
+	Sections     []BracketSection `json:"sections"`
+	ChampionID   uint             `json:"champion_id,omitempty"`   // Set once the tournament is finished
+	ChampionPath []uint           `json:"champion_path,omitempty"` // Matches the champion played, first to last
+}
+
+// GetBracket returns the bracket as a tree of rounds, slots, feeders and the winner path
+func GetBracket(w http.ResponseWriter, r *http.Request) {
+	tourIDStr := chi.URLParam(r, "id")
+	tourID, _ := strconv.Atoi(tourIDStr)
+
+	var t models.Tournament
+	if result := db.DB.Preload("Matches").Preload("TournamentParticipants.Participant").First(&t, tourID); result.Error != nil {
+		http.Error(w, "Tournament not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(buildBracketTree(t))
+}
+
+// buildBracketTree lays out the bracket matches of t. Single elimination is projected to the
+// final; double elimination depends on results, so only its generated rounds are listed.
+func buildBracketTree(t models.Tournament) BracketTree {
+	tree := BracketTree{TournamentID: t.ID, Format: t.BracketFormat, Sections: []BracketSection{}}
+
+	seeds := make(map[uint]int)
+	names := make(map[uint]string)
+	seeded := 0
+	for _, tp := range t.TournamentParticipants {
+		names[tp.ParticipantID] = tp.Participant.Nickname
+		if tp.Seed > 0 {
+			seeds[tp.ParticipantID] = tp.Seed
+			seeded++
+		}
+	}
+	if seeded < 2 {
+		return tree
+	}
+	tree.Size = nextPowerOfTwo(seeded)
+
+	// Winner and loser links are the feeder links read the other way
+	byID := make(map[uint]models.Match)
+	next := make(map[uint]uint)
+	loserNext := make(map[uint]uint)
+	byPhase := make(map[string][]models.Match)
+	for _, m := range t.Matches {
+		if !models.IsBracketPhase(m.Phase) {
+			continue
+		}
+		byID[m.ID] = m
+		byPhase[m.Phase] = append(byPhase[m.Phase], m)
+		for _, feeder := range []struct {
+			id    uint
+			loser bool
+		}{{m.Player1FeederID, m.Player1FeederLoser}, {m.Player2FeederID, m.Player2FeederLoser}} {
+			switch {
+			case feeder.id == 0:
+			case feeder.loser:
+				loserNext[feeder.id] = m.ID
+			default:
+				next[feeder.id] = m.ID
+			}
+		}
+	}
+
+	node := func(m models.Match) BracketMatch {
+		n := BracketMatch{
+			MatchID:          m.ID,
+			Round:            m.Round,
+			Slot:             m.Slot,
+			IsBye:            m.IsBye,
+			Decided:          m.Decided(),
+			Forfeit:          m.Forfeit,
+			WinnerID:         m.WinnerID,
+			NextMatchID:      next[m.ID],
+			LoserNextMatchID: loserNext[m.ID],
+		}
+		sides := []struct {
+			pid, feeder uint
+			loser       bool
+			score       int
+		}{
+			{m.Player1ID, m.Player1FeederID, m.Player1FeederLoser, m.ScoreP1},
+			{m.Player2ID, m.Player2FeederID, m.Player2FeederLoser, m.ScoreP2},
+		}
+		for i, side := range sides {
+			n.Players[i] = BracketPlayer{
+				ParticipantID: side.pid,
+				Nickname:      names[side.pid],
+				Seed:          seeds[side.pid],
+				Score:         side.score,
+				Winner:        side.pid != 0 && m.WinnerID != nil && *m.WinnerID == side.pid,
+				FeederMatchID: side.feeder,
+				FeederLoser:   side.loser,
+			}
+		}
+		return n
+	}
+
+	if t.BracketFormat == models.BracketDoubleElimination {
+		for _, phase := range []string{models.PhaseWinnersBracket, models.PhaseLosersBracket, models.PhaseGrandFinal} {
+			rounds := bracketColumns(byPhase[phase], node)
+			for r := range rounds {
+				rounds[r].Name = doubleEliminationRoundName(phase, rounds[r].Round)
+			}
+			tree.Sections = append(tree.Sections, BracketSection{Phase: phase, Rounds: rounds})
+		}
+		if finals := byPhase[models.PhaseGrandFinal]; t.Status == models.StatusFinished && len(finals) > 0 {
+			sortBracketMatches(finals)
+			tree.ChampionID = fedPlayer(finals[len(finals)-1], false)
+			tree.ChampionPath = championPath(finals[len(finals)-1], tree.ChampionID, byID)
+		}
+		return tree
+	}
+
+	// Single elimination: fill in the rounds still to come
+	totalRounds := 0
+	for size := tree.Size; size > 1; size /= 2 {
+		totalRounds++
+	}
+	rounds := bracketColumns(byPhase[models.PhaseBracket], node)
+	for len(rounds) < totalRounds {
+		rounds = append(rounds, BracketRound{Round: len(rounds) + 1})
+	}
+	for r := range rounds {
+		rounds[r].Name = singleEliminationRoundName(tree.Size >> (r + 1))
+		existing := rounds[r].Matches
+		filled := make([]BracketMatch, tree.Size>>(r+1))
+		for s := range filled {
+			filled[s] = BracketMatch{Round: r + 1, Slot: s}
+			if r > 0 {
+				// Slot s is fed by the winners of slots 2s and 2s+1 of the round before
+				filled[s].Players[0].FeederMatchID = rounds[r-1].Matches[2*s].MatchID
+				filled[s].Players[1].FeederMatchID = rounds[r-1].Matches[2*s+1].MatchID
+			}
+		}
+		for _, m := range existing {
+			if m.Slot < len(filled) {
+				filled[m.Slot] = m
+			}
+		}
+		rounds[r].Matches = filled
+	}
+	tree.Sections = append(tree.Sections, BracketSection{Phase: models.PhaseBracket, Rounds: rounds})
+
+	if t.ThirdPlaceMatch && totalRounds >= 2 {
+		third := BracketRound{Round: totalRounds, Name: "Third Place"}
+		semifinals := rounds[totalRounds-2].Matches
+		if played := byPhase[models.PhaseThirdPlace]; len(played) > 0 {
+			third.Matches = []BracketMatch{node(played[0])}
+		} else if !semifinals[0].IsBye && !semifinals[1].IsBye {
+			// Nobody plays for third when a semifinal was a bye
+			placeholder := BracketMatch{Round: totalRounds}
+			placeholder.Players[0] = BracketPlayer{FeederMatchID: semifinals[0].MatchID, FeederLoser: true}
+			placeholder.Players[1] = BracketPlayer{FeederMatchID: semifinals[1].MatchID, FeederLoser: true}
+			third.Matches = []BracketMatch{placeholder}
+		}
+		if len(third.Matches) > 0 {
+			tree.Sections = append(tree.Sections, BracketSection{Phase: models.PhaseThirdPlace, Rounds: []BracketRound{third}})
+		}
+	}
+
+	if final := rounds[totalRounds-1].Matches[0]; t.Status == models.StatusFinished && final.MatchID != 0 && final.WinnerID != nil {
+		tree.ChampionID = *final.WinnerID
+		tree.ChampionPath = championPath(byID[final.MatchID], tree.ChampionID, byID)
+	}
+	return tree
+}
+
+// bracketColumns groups the matches of one phase into rounds ordered by slot
+func bracketColumns(matches []models.Match, node func(models.Match) BracketMatch) []BracketRound {
+	matches = append([]models.Match{}, matches...)
+	sortBracketMatches(matches)
+
+	var rounds []BracketRound
+	for _, m := range matches {
+		if len(rounds) == 0 || rounds[len(rounds)-1].Round != m.Round {
+			rounds = append(rounds, BracketRound{Round: m.Round})
+		}
+		last := &rounds[len(rounds)-1]
+		last.Matches = append(last.Matches, node(m))
+	}
+	return rounds
+}
+
+// singleEliminationRoundName names a round by how many matches it holds
+func singleEliminationRoundName(matches int) string {
+	switch matches {
+	case 1:
+		return "Final"
+	case 2:
+		return "Semifinals"
+	case 4:
+		return "Quarterfinals"
+	}
+	return fmt.Sprintf("Round of %d", matches*2)
+}
+
+// doubleEliminationRoundName names a round of the winners bracket, losers bracket or grand final
+func doubleEliminationRoundName(phase string, round int) string {
+	switch phase {
+	case models.PhaseWinnersBracket:
+		return fmt.Sprintf("Winners Round %d", round)
+	case models.PhaseLosersBracket:
+		return fmt.Sprintf("Losers Round %d", round)
+	}
+	if round == 2 {
+		return "Bracket Reset"
+	}
+	return "Grand Final"
+}
+
+// championPath follows the champion's feeder links back from the last match they won, and
+// returns the matches in the order they were played
+func championPath(last models.Match, championID uint, byID map[uint]models.Match) []uint {
+	var path []uint
+	for m, ok := last, true; ok; {
+		path = append([]uint{m.ID}, path...)
+		feeder := m.Player1FeederID
+		if m.Player2ID == championID {
+			feeder = m.Player2FeederID
+		}
+		m, ok = byID[feeder]
+	}
+	return path
+}
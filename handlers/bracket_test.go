@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"bbx_tournament/models"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestBuildBracketTreeSingleElimination(t *testing.T) {
+	tournament := models.Tournament{BracketFormat: models.BracketSingleElimination, ThirdPlaceMatch: true}
+	var seeds []uint
+	for pid := uint(1); pid <= 6; pid++ {
+		tournament.TournamentParticipants = append(tournament.TournamentParticipants, models.TournamentParticipant{
+			ParticipantID: pid,
+			Participant:   models.Participant{Nickname: "Blader"},
+			Seed:          int(pid),
+		})
+		seeds = append(seeds, pid)
+	}
+
+	// Store a round the way Advance does, the lower ID winning every match
+	nextID := uint(1)
+	store := func(matches []models.Match) []models.Match {
+		linkFeeders(matches, tournament.Matches)
+		for i := range matches {
+			matches[i].Model = gorm.Model{ID: nextID}
+			nextID++
+			winner := matches[i].Player1ID
+			if matches[i].Player2ID != 0 && matches[i].Player2ID < winner {
+				winner = matches[i].Player2ID
+			}
+			matches[i].WinnerID = &winner
+		}
+		tournament.Matches = append(tournament.Matches, matches...)
+		return matches
+	}
+
+	first := store(seedBracketMatches(1, seeds, models.PhaseBracket))
+	tree := buildBracketTree(tournament)
+	if tree.Size != 8 || len(tree.Sections) != 2 {
+		t.Fatalf("expected a bracket of 8 with a match for third, got size %d and %d sections", tree.Size, len(tree.Sections))
+	}
+	var names []string
+	for _, round := range tree.Sections[0].Rounds {
+		names = append(names, round.Name)
+	}
+	if expected := []string{"Quarterfinals", "Semifinals", "Final"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected rounds %v, got %v", expected, names)
+	}
+	semifinal := tree.Sections[0].Rounds[1].Matches[1]
+	if semifinal.MatchID != 0 || semifinal.Players[0].FeederMatchID != first[2].ID || semifinal.Players[1].FeederMatchID != first[3].ID {
+		t.Errorf("expected the second semifinal to wait on matches %d and %d, got %+v", first[2].ID, first[3].ID, semifinal)
+	}
+
+	semifinals := store(generateBracketMatches(1, []uint{1, 4, 2, 3}, 2, models.PhaseBracket))
+	final := store(append(generateBracketMatches(1, []uint{1, 2}, 3, models.PhaseBracket), thirdPlaceMatch(tournament, semifinals, 3)...))
+	tree = buildBracketTree(tournament)
+
+	if got := tree.Sections[0].Rounds[0].Matches[0]; !got.IsBye || got.NextMatchID != semifinals[0].ID {
+		t.Errorf("expected seed 1's bye to lead to match %d, got %+v", semifinals[0].ID, got)
+	}
+	if got := tree.Sections[0].Rounds[1].Matches[0]; got.NextMatchID != final[0].ID || got.LoserNextMatchID != final[1].ID {
+		t.Errorf("expected the semifinal to feed the final and the match for third, got %+v", got)
+	}
+	if got := tree.Sections[1].Rounds[0].Matches[0]; got.MatchID != final[1].ID || got.Players[0].ParticipantID != 4 {
+		t.Errorf("expected seed 4 in the match for third, got %+v", got)
+	}
+	if tree.ChampionID != 0 {
+		t.Errorf("expected no champion before the tournament is finished, got %d", tree.ChampionID)
+	}
+
+	tournament.Status = models.StatusFinished
+	tree = buildBracketTree(tournament)
+	expectedPath := []uint{first[0].ID, semifinals[0].ID, final[0].ID}
+	if tree.ChampionID != 1 || !reflect.DeepEqual(tree.ChampionPath, expectedPath) {
+		t.Errorf("expected champion 1 through %v, got %d through %v", expectedPath, tree.ChampionID, tree.ChampionPath)
+	}
+}
+
+func TestBuildBracketTreeDoubleElimination(t *testing.T) {
+	tournament := models.Tournament{BracketFormat: models.BracketDoubleElimination, BracketReset: true, Status: models.StatusBracketInProgress}
+	var seeds []uint
+	for pid := uint(1); pid <= 4; pid++ {
+		tournament.TournamentParticipants = append(tournament.TournamentParticipants, models.TournamentParticipant{
+			ParticipantID: pid,
+			Participant:   models.Participant{Nickname: "Blader"},
+			Seed:          int(pid),
+		})
+		seeds = append(seeds, pid)
+	}
+
+	// The lower ID wins every match but the grand finals, which the losers bracket side takes
+	nextID := uint(1)
+	next := seedBracketMatches(1, seeds, models.PhaseWinnersBracket)
+	for finished := false; !finished; next, finished = nextDoubleEliminationMatches(tournament, tournament.Matches) {
+		linkFeeders(next, tournament.Matches)
+		for i := range next {
+			next[i].Model = gorm.Model{ID: nextID}
+			nextID++
+			winner := next[i].Player2ID
+			if next[i].Phase != models.PhaseGrandFinal && next[i].Player1ID < winner {
+				winner = next[i].Player1ID
+			}
+			next[i].WinnerID = &winner
+		}
+		tournament.Matches = append(tournament.Matches, next...)
+	}
+
+	tree := buildBracketTree(tournament)
+	names := make(map[string][]string)
+	for _, section := range tree.Sections {
+		for _, round := range section.Rounds {
+			names[section.Phase] = append(names[section.Phase], round.Name)
+		}
+	}
+	expected := map[string][]string{
+		models.PhaseWinnersBracket: {"Winners Round 1", "Winners Round 2"},
+		models.PhaseLosersBracket:  {"Losers Round 1", "Losers Round 2"},
+		models.PhaseGrandFinal:     {"Grand Final", "Bracket Reset"},
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected rounds %v, got %v", expected, names)
+	}
+	if tree.ChampionID != 0 {
+		t.Errorf("expected no champion before the tournament is finished, got %d", tree.ChampionID)
+	}
+
+	// 2 loses the winners final to 1, comes back through the losers bracket and wins both grand finals
+	tournament.Status = models.StatusFinished
+	tree = buildBracketTree(tournament)
+	var expectedPath []uint
+	for _, m := range tournament.Matches {
+		if m.Player1ID == 2 || m.Player2ID == 2 {
+			expectedPath = append(expectedPath, m.ID)
+		}
+	}
+	if len(expectedPath) != 5 {
+		t.Fatalf("expected 2 to play 5 matches, got %v", expectedPath)
+	}
+	if tree.ChampionID != 2 || !reflect.DeepEqual(tree.ChampionPath, expectedPath) {
+		t.Errorf("expected champion 2 through %v, got %d through %v", expectedPath, tree.ChampionID, tree.ChampionPath)
+	}
+
+	// The winners final sends its loser to the losers final
+	winnersFinal := tree.Sections[0].Rounds[1].Matches[0]
+	losersFinal := tree.Sections[1].Rounds[1].Matches[0]
+	if winnersFinal.LoserNextMatchID != losersFinal.MatchID || winnersFinal.NextMatchID != tree.Sections[2].Rounds[0].Matches[0].MatchID {
+		t.Errorf("expected the winners final to feed the grand final and the losers final, got %+v", winnersFinal)
+	}
+}
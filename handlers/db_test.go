@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"bbx_tournament/db"
+	"bbx_tournament/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB points db.DB at a fresh in-memory database for the length of the test
+func openTestDB(t *testing.T) {
+	t.Helper()
+	// Connections of the pool share the named database
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	conn, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	if err := db.Migrate(conn); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+
+	previous := db.DB
+	db.DB = conn
+	t.Cleanup(func() {
+		db.DB = previous
+		if sqlDB, err := conn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+// serve runs a handler through a router mounted on pattern, so URL parameters resolve as in main
+func serve(t *testing.T, handler http.HandlerFunc, method, pattern, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatalf("encode request: %v", err)
+		}
+	}
+
+	r := chi.NewRouter()
+	r.MethodFunc(method, pattern, handler)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, path, &payload))
+	return w
+}
+
+// decode reads a JSON response into out
+func decode(t *testing.T, w *httptest.ResponseRecorder, out interface{}) {
+	t.Helper()
+	if err := json.NewDecoder(w.Body).Decode(out); err != nil {
+		t.Fatalf("decode response %q: %v", w.Body.String(), err)
+	}
+}
+
+func TestMigrateKeepsPenaltiesSwitchedOff(t *testing.T) {
+	openTestDB(t)
+	rules := models.DefaultRuleSet()
+	rules.PenaltyWarnings, rules.PenaltyPoints = 0, 0
+	tournament := models.Tournament{Name: "Cup", Rules: rules}
+	db.DB.Create(&tournament)
+
+	// Migrate runs again on every start
+	if err := db.Migrate(db.DB); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	got, _ := tournamentRules(db.DB, tournament.ID)
+	if got.PenaltyWarnings != 0 || got.PenaltyPoints != 0 {
+		t.Errorf("expected the explicit 0/0 kept, got %d warnings and %d points", got.PenaltyWarnings, got.PenaltyPoints)
+	}
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"bbx_tournament/db"
+	"bbx_tournament/models"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// DeckRequest represents the payload for creating or replacing a deck
+type DeckRequest struct {
+	Name      string            `json:"name"`
+	Beyblades []models.Beyblade `json:"beyblades"`
+}
+
+// GetDecks lists all decks of a participant with their Beyblades
+func GetDecks(w http.ResponseWriter, r *http.Request) {
+	participantID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var decks []models.Deck
+	if result := db.DB.Preload("Beyblades").Where("participant_id = ?", participantID).Find(&decks); result.Error != nil {
+		http.Error(w, result.Error.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(decks)
+}
+
+// GetDeck returns a single deck of a participant
+func GetDeck(w http.ResponseWriter, r *http.Request) {
+	deck, ok := loadDeck(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deck)
+}
+
+// CreateDeck registers a new deck for a participant
+func CreateDeck(w http.ResponseWriter, r *http.Request) {
+	participantID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var req DeckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rules, ok := deckRulesFromQuery(w, r)
+	if !ok {
+		return
+	}
+	if violations := validateDeck(req.Beyblades, rules); len(violations) > 0 {
+		writeDeckViolations(w, rules, violations)
+		return
+	}
+
+	var p models.Participant
+	if result := db.DB.First(&p, participantID); result.Error != nil {
+		http.Error(w, "Participant not found", http.StatusNotFound)
+		return
+	}
+
+	deck := models.Deck{
+		ParticipantID: p.ID,
+		Name:          req.Name,
+		Beyblades:     cleanBeyblades(req.Beyblades),
+	}
+
+	// Beyblades are created together with the deck through the association
+	if result := db.DB.Create(&deck); result.Error != nil {
+		http.Error(w, result.Error.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(deck)
+}
+
+// UpdateDeck renames a deck and replaces its Beyblades
+func UpdateDeck(w http.ResponseWriter, r *http.Request) {
+	deck, ok := loadDeck(w, r)
+	if !ok {
+		return
+	}
+
+	var req DeckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rules, ok := deckRulesFromQuery(w, r)
+	if !ok {
+		return
+	}
+	if violations := validateDeck(req.Beyblades, rules); len(violations) > 0 {
+		writeDeckViolations(w, rules, violations)
+		return
+	}
+
+	// The deck must stay legal wherever it is entered
+	registered, err := deckRegistrations(deck.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, t := range registered {
+		if t.DeckRules == "" {
+			t.DeckRules = models.DeckRulesStandard
+		}
+		if violations := validateDeck(req.Beyblades, t.DeckRules); len(violations) > 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":         "Deck would not be legal in " + t.Name + ", where it is registered",
+				"tournament_id": t.ID,
+				"rules":         t.DeckRules,
+				"violations":    violations,
+			})
+			return
+		}
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		// Replace the combos wholesale, the list is only ever three entries long
+		if err := tx.Where("deck_id = ?", deck.ID).Delete(&models.Beyblade{}).Error; err != nil {
+			return err
+		}
+
+		deck.Name = req.Name
+		deck.Beyblades = cleanBeyblades(req.Beyblades)
+		for i := range deck.Beyblades {
+			deck.Beyblades[i].DeckID = deck.ID
+		}
+		if len(deck.Beyblades) > 0 {
+			if err := tx.Create(&deck.Beyblades).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Omit("Beyblades").Save(&deck).Error
+	})
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deck)
+}
+
+// DeleteDeck removes a deck and its Beyblades, unless a tournament still in play has it registered
+func DeleteDeck(w http.ResponseWriter, r *http.Request) {
+	deck, ok := loadDeck(w, r)
+	if !ok {
+		return
+	}
+
+	registered, err := deckRegistrations(deck.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(registered) > 0 {
+		http.Error(w, "Deck is registered in "+registered[0].Name+", which has not finished", http.StatusConflict)
+		return
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("deck_id = ?", deck.ID).Delete(&models.Beyblade{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&deck).Error
+	})
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"status": "deleted"}`))
+}
+
+// loadDeck fetches the deck from the URL and makes sure it belongs to the participant
+func loadDeck(w http.ResponseWriter, r *http.Request) (models.Deck, bool) {
+	var deck models.Deck
+
+	participantID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return deck, false
+	}
+	deckID, err := strconv.Atoi(chi.URLParam(r, "deckID"))
+	if err != nil {
+		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		return deck, false
+	}
+
+	err = db.DB.Preload("Beyblades").Where("participant_id = ?", participantID).First(&deck, deckID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return deck, false
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return deck, false
+	}
+
+	return deck, true
+}
+
+// deckRegistrations lists the tournaments, not finished yet, the deck is registered in
+func deckRegistrations(deckID uint) ([]models.Tournament, error) {
+	var tournaments []models.Tournament
+	err := db.DB.Where("status <> ?", models.StatusFinished).
+		Where("id IN (?)", db.DB.Model(&models.TournamentParticipant{}).Select("tournament_id").Where("deck_id = ?", deckID)).
+		Order("id ASC").Find(&tournaments).Error
+	return tournaments, err
+}
+
+// deckRulesFromQuery reads ?rules=, decks are checked against the standard rules unless asked otherwise
+func deckRulesFromQuery(w http.ResponseWriter, r *http.Request) (string, bool) {
+	rules := r.URL.Query().Get("rules")
+	if rules == "" {
+		return models.DeckRulesStandard, true
+	}
+	if !validDeckRules(rules) {
+		http.Error(w, "Invalid deck rules", http.StatusBadRequest)
+		return "", false
+	}
+	return rules, true
+}
+
+// cleanBeyblades drops client-supplied IDs so every save creates fresh rows
+func cleanBeyblades(in []models.Beyblade) []models.Beyblade {
+	out := make([]models.Beyblade, 0, len(in))
+	for _, b := range in {
+		out = append(out, models.Beyblade{
+			Blade:   b.Blade,
+			Ratchet: b.Ratchet,
+			Bit:     b.Bit,
+		})
+	}
+	return out
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"bbx_tournament/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DeckViolation describes one broken deck rule and the Beyblades involved
+type DeckViolation struct {
+	Rule            string            `json:"rule"` // deck_size, incomplete_combo, duplicate_blade, duplicate_ratchet, duplicate_bit
+	Message         string            `json:"message"`
+	BeybladeIndexes []int             `json:"beyblade_indexes"` // Positions in the submitted list
+	Beyblades       []models.Beyblade `json:"beyblades"`
+}
+
+// validDeckRules reports whether the rule set name is known
+func validDeckRules(rules string) bool {
+	return rules == models.DeckRulesStandard || rules == models.DeckRulesCasual
+}
+
+// validateDeck checks the Beyblades of a deck against a rule set and returns every violation found
+func validateDeck(beyblades []models.Beyblade, rules string) []DeckViolation {
+	var violations []DeckViolation
+
+	switch rules {
+	case models.DeckRulesCasual:
+		if len(beyblades) < 1 || len(beyblades) > 3 {
+			violations = append(violations, DeckViolation{
+				Rule:    "deck_size",
+				Message: fmt.Sprintf("A casual deck needs one to three Beyblades, got %d", len(beyblades)),
+			})
+		}
+	default:
+		if len(beyblades) != 3 {
+			violations = append(violations, DeckViolation{
+				Rule:    "deck_size",
+				Message: fmt.Sprintf("A deck needs exactly three Beyblades, got %d", len(beyblades)),
+			})
+		}
+	}
+
+	for i, b := range beyblades {
+		if normalizePart(b.Blade) == "" || normalizePart(b.Ratchet) == "" || normalizePart(b.Bit) == "" {
+			violations = append(violations, DeckViolation{
+				Rule:            "incomplete_combo",
+				Message:         fmt.Sprintf("Beyblade %d needs a Blade, a Ratchet and a Bit", i+1),
+				BeybladeIndexes: []int{i},
+				Beyblades:       []models.Beyblade{b},
+			})
+		}
+	}
+
+	if rules == models.DeckRulesCasual {
+		return violations
+	}
+
+	// No part may appear twice across the deck
+	parts := []struct {
+		rule string
+		name string
+		get  func(models.Beyblade) string
+	}{
+		{"duplicate_blade", "Blade", func(b models.Beyblade) string { return b.Blade }},
+		{"duplicate_ratchet", "Ratchet", func(b models.Beyblade) string { return b.Ratchet }},
+		{"duplicate_bit", "Bit", func(b models.Beyblade) string { return b.Bit }},
+	}
+	for _, part := range parts {
+		seen := make(map[string][]int)
+		var order []string
+		for i, b := range beyblades {
+			key := normalizePart(part.get(b))
+			if key == "" {
+				continue
+			}
+			if _, ok := seen[key]; !ok {
+				order = append(order, key)
+			}
+			seen[key] = append(seen[key], i)
+		}
+		for _, key := range order {
+			indexes := seen[key]
+			if len(indexes) < 2 {
+				continue
+			}
+			v := DeckViolation{
+				Rule:            part.rule,
+				Message:         fmt.Sprintf("%s %q is used %d times", part.name, part.get(beyblades[indexes[0]]), len(indexes)),
+				BeybladeIndexes: indexes,
+			}
+			for _, i := range indexes {
+				v.Beyblades = append(v.Beyblades, beyblades[i])
+			}
+			violations = append(violations, v)
+		}
+	}
+
+	return violations
+}
+
+// normalizePart makes "dran sword " and "Dran Sword" count as the same part
+func normalizePart(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// writeDeckViolations responds with the structured list of broken rules
+func writeDeckViolations(w http.ResponseWriter, rules string, violations []DeckViolation) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":      "Deck is not legal",
+		"rules":      rules,
+		"violations": violations,
+	})
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"bbx_tournament/models"
+	"testing"
+)
+
+func TestValidateDeck(t *testing.T) {
+	legal := []models.Beyblade{
+		{Blade: "Dran Sword", Ratchet: "3-60", Bit: "Flat"},
+		{Blade: "Hells Scythe", Ratchet: "4-60", Bit: "Taper"},
+		{Blade: "Wizard Arrow", Ratchet: "4-80", Bit: "Ball"},
+	}
+	duplicated := []models.Beyblade{
+		{Blade: "Dran Sword", Ratchet: "3-60", Bit: "Flat"},
+		{Blade: "dran sword ", Ratchet: "4-60", Bit: "Taper"},
+		{Blade: "Wizard Arrow", Ratchet: "3-60", Bit: "Ball"},
+	}
+
+	tests := []struct {
+		name      string
+		beyblades []models.Beyblade
+		rules     string
+		expected  []string
+	}{
+		{"Legal standard deck", legal, models.DeckRulesStandard, nil},
+		{"Too few Beyblades", legal[:2], models.DeckRulesStandard, []string{"deck_size"}},
+		{"Repeated parts", duplicated, models.DeckRulesStandard, []string{"duplicate_blade", "duplicate_ratchet"}},
+		{"Incomplete combo", []models.Beyblade{legal[0], legal[1], {Blade: "Wizard Arrow"}}, models.DeckRulesStandard, []string{"incomplete_combo"}},
+		{"Casual allows repeats", duplicated, models.DeckRulesCasual, nil},
+		{"Casual allows a single Beyblade", legal[:1], models.DeckRulesCasual, nil},
+		{"Casual still caps the deck", append(legal, legal[0]), models.DeckRulesCasual, []string{"deck_size"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := validateDeck(tt.beyblades, tt.rules)
+			if len(violations) != len(tt.expected) {
+				t.Fatalf("expected %d violations, got %+v", len(tt.expected), violations)
+			}
+			for i, v := range violations {
+				if v.Rule != tt.expected[i] {
+					t.Errorf("expected rule %s, got %s", tt.expected[i], v.Rule)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateDeckReportsOffendingBeyblades(t *testing.T) {
+	violations := validateDeck([]models.Beyblade{
+		{Blade: "Dran Sword", Ratchet: "3-60", Bit: "Flat"},
+		{Blade: "Hells Scythe", Ratchet: "4-60", Bit: "Taper"},
+		{Blade: "Wizard Arrow", Ratchet: "4-80", Bit: "Flat"},
+	}, models.DeckRulesStandard)
+
+	if len(violations) != 1 || violations[0].Rule != "duplicate_bit" {
+		t.Fatalf("expected a single duplicate_bit violation, got %+v", violations)
+	}
+	if idx := violations[0].BeybladeIndexes; len(idx) != 2 || idx[0] != 0 || idx[1] != 2 {
+		t.Errorf("expected Beyblades 0 and 2, got %v", idx)
+	}
+}
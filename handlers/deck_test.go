@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"bbx_tournament/db"
+	"bbx_tournament/models"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+const deckPattern = "/participants/{id}/decks/{deckID}"
+
+func standardDeck(name string) DeckRequest {
+	return DeckRequest{Name: name, Beyblades: []models.Beyblade{
+		{Blade: "Dran Sword", Ratchet: "3-60", Bit: "Flat"},
+		{Blade: "Hells Scythe", Ratchet: "4-60", Bit: "Taper"},
+		{Blade: "Wizard Arrow", Ratchet: "4-80", Bit: "Ball"},
+	}}
+}
+
+func createTestParticipant(t *testing.T, nickname string) models.Participant {
+	t.Helper()
+	p := models.Participant{Nickname: nickname}
+	if err := db.DB.Create(&p).Error; err != nil {
+		t.Fatalf("create participant: %v", err)
+	}
+	return p
+}
+
+func createTestDeck(t *testing.T, p models.Participant, req DeckRequest) models.Deck {
+	t.Helper()
+	w := serve(t, CreateDeck, http.MethodPost, "/participants/{id}/decks", fmt.Sprintf("/participants/%d/decks", p.ID), req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create deck: %d %s", w.Code, w.Body.String())
+	}
+	var deck models.Deck
+	decode(t, w, &deck)
+	return deck
+}
+
+func TestDeckCRUD(t *testing.T) {
+	openTestDB(t)
+	p := createTestParticipant(t, "Aiger")
+
+	deck := createTestDeck(t, p, standardDeck("Attack"))
+	if deck.ParticipantID != p.ID || len(deck.Beyblades) != 3 {
+		t.Fatalf("unexpected created deck %+v", deck)
+	}
+	path := fmt.Sprintf("/participants/%d/decks/%d", p.ID, deck.ID)
+
+	w := serve(t, GetDeck, http.MethodGet, deckPattern, path, nil)
+	var got models.Deck
+	decode(t, w, &got)
+	if got.Name != "Attack" || len(got.Beyblades) != 3 || got.Beyblades[0].Blade != "Dran Sword" {
+		t.Errorf("unexpected deck %+v", got)
+	}
+
+	update := standardDeck("Balance")
+	update.Beyblades[0].Blade = "Phoenix Wing"
+	w = serve(t, UpdateDeck, http.MethodPut, deckPattern, path, update)
+	if w.Code != http.StatusOK {
+		t.Fatalf("update deck: %d %s", w.Code, w.Body.String())
+	}
+	w = serve(t, GetDeck, http.MethodGet, deckPattern, path, nil)
+	decode(t, w, &got)
+	if got.Name != "Balance" || len(got.Beyblades) != 3 || got.Beyblades[0].Blade != "Phoenix Wing" {
+		t.Errorf("expected the updated deck, got %+v", got)
+	}
+
+	w = serve(t, GetDecks, http.MethodGet, "/participants/{id}/decks", fmt.Sprintf("/participants/%d/decks", p.ID), nil)
+	var decks []models.Deck
+	decode(t, w, &decks)
+	if len(decks) != 1 {
+		t.Errorf("expected 1 deck, got %d", len(decks))
+	}
+
+	if w = serve(t, DeleteDeck, http.MethodDelete, deckPattern, path, nil); w.Code != http.StatusOK {
+		t.Fatalf("delete deck: %d %s", w.Code, w.Body.String())
+	}
+	if w = serve(t, GetDeck, http.MethodGet, deckPattern, path, nil); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a deleted deck, got %d", w.Code)
+	}
+	var left int64
+	db.DB.Model(&models.Beyblade{}).Where("deck_id = ?", deck.ID).Count(&left)
+	if left != 0 {
+		t.Errorf("expected the deck's Beyblades deleted, %d left", left)
+	}
+}
+
+func TestDeckOwnedByAnotherParticipant(t *testing.T) {
+	openTestDB(t)
+	owner := createTestParticipant(t, "Aiger")
+	other := createTestParticipant(t, "Multi")
+	deck := createTestDeck(t, owner, standardDeck("Attack"))
+
+	path := fmt.Sprintf("/participants/%d/decks/%d", other.ID, deck.ID)
+	if w := serve(t, GetDeck, http.MethodGet, deckPattern, path, nil); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 reading another participant's deck, got %d", w.Code)
+	}
+	if w := serve(t, UpdateDeck, http.MethodPut, deckPattern, path, standardDeck("Stolen")); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 updating another participant's deck, got %d", w.Code)
+	}
+	if w := serve(t, DeleteDeck, http.MethodDelete, deckPattern, path, nil); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 deleting another participant's deck, got %d", w.Code)
+	}
+
+	var kept models.Deck
+	if err := db.DB.Preload("Beyblades").First(&kept, deck.ID).Error; err != nil || kept.Name != "Attack" {
+		t.Errorf("expected the owner's deck untouched, got %+v (%v)", kept, err)
+	}
+}
+
+func TestCreateDeckUnknownParticipant(t *testing.T) {
+	openTestDB(t)
+	w := serve(t, CreateDeck, http.MethodPost, "/participants/{id}/decks", "/participants/99/decks", standardDeck("Attack"))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown participant, got %d", w.Code)
+	}
+}
+
+func TestRegisteredDeck(t *testing.T) {
+	openTestDB(t)
+	p := createTestParticipant(t, "Aiger")
+	deck := createTestDeck(t, p, standardDeck("Attack"))
+	path := fmt.Sprintf("/participants/%d/decks/%d", p.ID, deck.ID)
+
+	tournament := models.Tournament{Name: "Cup", Status: models.StatusInProgress, DeckRules: models.DeckRulesStandard}
+	db.DB.Create(&tournament)
+	db.DB.Create(&models.TournamentParticipant{TournamentID: tournament.ID, ParticipantID: p.ID, DeckID: &deck.ID})
+
+	// Legal under ?rules=casual, but the deck is entered under the standard rules
+	repeated := standardDeck("Attack")
+	repeated.Beyblades[1].Blade = "Dran Sword"
+	if w := serve(t, UpdateDeck, http.MethodPut, deckPattern, path+"?rules=casual", repeated); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for an edit breaking a registration, got %d", w.Code)
+	}
+	if w := serve(t, UpdateDeck, http.MethodPut, deckPattern, path, standardDeck("Renamed")); w.Code != http.StatusOK {
+		t.Errorf("expected a legal edit to go through, got %d %s", w.Code, w.Body.String())
+	}
+	if w := serve(t, DeleteDeck, http.MethodDelete, deckPattern, path, nil); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 deleting a registered deck, got %d", w.Code)
+	}
+
+	// Once the tournament is over the deck is free again
+	db.DB.Model(&tournament).Update("status", models.StatusFinished)
+	if w := serve(t, UpdateDeck, http.MethodPut, deckPattern, path+"?rules=casual", repeated); w.Code != http.StatusOK {
+		t.Errorf("expected the edit allowed after the tournament finished, got %d", w.Code)
+	}
+	if w := serve(t, DeleteDeck, http.MethodDelete, deckPattern, path, nil); w.Code != http.StatusOK {
+		t.Errorf("expected the delete allowed after the tournament finished, got %d", w.Code)
+	}
+}
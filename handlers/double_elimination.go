@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"bbx_tournament/models"
+	"sort"
+)
+
+// nextDoubleEliminationMatches generates everything that can be played next in a double-elimination
+// bracket once all current matches are decided. State is derived from the match history alone:
+// players with no loss are in the winners bracket, players with one loss in the losers bracket.
+// It returns finished once the grand final (and its reset, if enabled and needed) is decided.
+func nextDoubleEliminationMatches(t models.Tournament, matches []models.Match) (next []models.Match, finished bool) {
+	var winners, losers, finals []models.Match
+	for _, m := range matches {
+		switch m.Phase {
+		case models.PhaseWinnersBracket:
+			winners = append(winners, m)
+		case models.PhaseLosersBracket:
+			losers = append(losers, m)
+		case models.PhaseGrandFinal:
+			finals = append(finals, m)
+		}
+	}
+	sortBracketMatches(winners)
+	sortBracketMatches(losers)
+	sortBracketMatches(finals)
+
+	// Grand final: player 1 comes from the winners bracket, player 2 from the losers bracket
+	if len(finals) > 0 {
+		last := finals[len(finals)-1]
+		if last.Round == 1 && t.BracketReset && last.WinnerID != nil && *last.WinnerID == last.Player2ID {
+			return []models.Match{{
+				TournamentID: t.ID,
+				Player1ID:    last.Player1ID,
+				Player2ID:    last.Player2ID,
+				Phase:        models.PhaseGrandFinal,
+				Round:        2,
+			}}, false
+		}
+		return nil, true
+	}
+
+	losses := make(map[uint]int)
+	for _, m := range append(append([]models.Match{}, winners...), losers...) {
+		if m.WinnerID != nil && !m.IsBye {
+			losses[loserOf(m)]++
+		}
+	}
+
+	// Winners bracket keeps going round by round until one player is left
+	wbRound := 0
+	for _, m := range winners {
+		if m.Round > wbRound {
+			wbRound = m.Round
+		}
+	}
+	var wbAlive []uint
+	for _, m := range winners {
+		if m.Round == wbRound && m.WinnerID != nil {
+			wbAlive = append(wbAlive, *m.WinnerID)
+		}
+	}
+	if len(wbAlive) > 1 {
+		next = append(next, generateBracketMatches(t.ID, wbAlive, wbRound+1, models.PhaseWinnersBracket)...)
+	}
+
+	// Losers bracket: survivors have already won in it, dropouts are waiting for their first match.
+	// Dropouts are batched by the winners round they lost in and join in that order.
+	playedLosers := make(map[uint]bool)
+	var survivors []uint
+	for _, m := range losers {
+		for _, pid := range []uint{m.Player1ID, m.Player2ID} {
+			if pid != 0 && !playedLosers[pid] {
+				playedLosers[pid] = true
+				if losses[pid] == 1 {
+					survivors = append(survivors, pid)
+				}
+			}
+		}
+	}
+	survivors = orderByLatestMatch(survivors, losers)
+
+	var batches [][]uint
+	batchRound := 0
+	for _, m := range winners {
+		if m.IsBye || m.WinnerID == nil {
+			continue
+		}
+		pid := loserOf(m)
+		if playedLosers[pid] || losses[pid] != 1 {
+			continue
+		}
+		if len(batches) == 0 || m.Round != batchRound {
+			batches = append(batches, nil)
+			batchRound = m.Round
+		}
+		batches[len(batches)-1] = append(batches[len(batches)-1], pid)
+	}
+
+	waiting := 0
+	for _, b := range batches {
+		waiting += len(b)
+	}
+
+	// Both brackets are down to one player each
+	if len(wbAlive) == 1 && len(survivors)+waiting == 1 {
+		lbChampion := append(survivors, flatten(batches)...)[0]
+		return []models.Match{{
+			TournamentID: t.ID,
+			Player1ID:    wbAlive[0],
+			Player2ID:    lbChampion,
+			Phase:        models.PhaseGrandFinal,
+			Round:        1,
+		}}, false
+	}
+
+	lbRound := 0
+	for _, m := range losers {
+		if m.Round > lbRound {
+			lbRound = m.Round
+		}
+	}
+
+	var pairs [][2]uint
+	switch {
+	case len(survivors) == 0:
+		// Opening losers round, everyone waiting plays each other
+		pairs = pairInOrder(flatten(batches))
+	case len(batches) == 0 || len(survivors) > len(batches[0]):
+		// Minor round among survivors while the next dropouts wait
+		if len(survivors) > 1 {
+			pairs = pairInOrder(survivors)
+		}
+	default:
+		// Major round: the oldest dropouts come down against the survivors, flipped to avoid rematches
+		batch := batches[0]
+		for i, pid := range survivors {
+			pairs = append(pairs, [2]uint{pid, batch[len(batch)-1-i]})
+		}
+		pairs = append(pairs, pairInOrder(batch[:len(batch)-len(survivors)])...)
+	}
+
+	for i, p := range pairs {
+		m := models.Match{
+			TournamentID: t.ID,
+			Player1ID:    p[0],
+			Player2ID:    p[1],
+			Phase:        models.PhaseLosersBracket,
+			Round:        lbRound + 1,
+			Slot:         i,
+		}
+		if p[1] == 0 {
+			winner := p[0]
+			m.WinnerID = &winner
+			m.IsBye = true
+		}
+		next = append(next, m)
+	}
+
+	return next, false
+}
+
+// pairInOrder pairs players first vs second, third vs fourth; an odd player out gets a bye (partner 0)
+func pairInOrder(players []uint) [][2]uint {
+	var pairs [][2]uint
+	for i := 0; i < len(players); i += 2 {
+		if i+1 < len(players) {
+			pairs = append(pairs, [2]uint{players[i], players[i+1]})
+		} else {
+			pairs = append(pairs, [2]uint{players[i], 0})
+		}
+	}
+	return pairs
+}
+
+// orderByLatestMatch sorts players by the round and slot of the last match they appear in
+func orderByLatestMatch(players []uint, matches []models.Match) []uint {
+	type position struct{ round, slot int }
+	latest := make(map[uint]position)
+	for _, m := range matches {
+		for _, pid := range []uint{m.Player1ID, m.Player2ID} {
+			latest[pid] = position{m.Round, m.Slot}
+		}
+	}
+	sort.SliceStable(players, func(i, j int) bool {
+		a, b := latest[players[i]], latest[players[j]]
+		if a.round != b.round {
+			return a.round < b.round
+		}
+		return a.slot < b.slot
+	})
+	return players
+}
+
+// sortBracketMatches orders matches by round, then slot
+func sortBracketMatches(matches []models.Match) {
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Round != matches[j].Round {
+			return matches[i].Round < matches[j].Round
+		}
+		return matches[i].Slot < matches[j].Slot
+	})
+}
+
+// loserOf returns the player who did not win a decided match
+func loserOf(m models.Match) uint {
+	if m.WinnerID != nil && *m.WinnerID == m.Player1ID {
+		return m.Player2ID
+	}
+	return m.Player1ID
+}
+
+// flatten joins dropout batches, oldest first
+func flatten(batches [][]uint) []uint {
+	var out []uint
+	for _, b := range batches {
+		out = append(out, b...)
+	}
+	return out
+}
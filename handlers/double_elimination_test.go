@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"bbx_tournament/models"
+	"math/rand"
+	"testing"
+)
+
+// simulateDoubleElimination plays a full bracket, picking winners with pick, and returns every match
+func simulateDoubleElimination(t *testing.T, players int, reset bool, pick func(m models.Match) uint) []models.Match {
+	t.Helper()
+
+	seeds := make([]uint, players)
+	for i := range seeds {
+		seeds[i] = uint(i + 1)
+	}
+	tournament := models.Tournament{BracketFormat: models.BracketDoubleElimination, BracketReset: reset}
+	matches := seedBracketMatches(1, seeds, models.PhaseWinnersBracket)
+
+	for step := 0; step < 4*players; step++ {
+		for i := range matches {
+			if matches[i].WinnerID == nil {
+				winner := pick(matches[i])
+				matches[i].WinnerID = &winner
+			}
+		}
+		next, finished := nextDoubleEliminationMatches(tournament, matches)
+		if finished {
+			return matches
+		}
+		if len(next) == 0 {
+			t.Fatalf("%d players: bracket stalled after %d steps", players, step)
+		}
+		matches = append(matches, next...)
+	}
+	t.Fatalf("%d players: bracket never finished", players)
+	return nil
+}
+
+func TestDoubleEliminationCompletes(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	for players := 2; players <= 17; players++ {
+		for _, reset := range []bool{false, true} {
+			matches := simulateDoubleElimination(t, players, reset, func(m models.Match) uint {
+				if rng.Intn(2) == 0 {
+					return m.Player1ID
+				}
+				return m.Player2ID
+			})
+
+			losses := make(map[uint]int)
+			played := 0
+			for _, m := range matches {
+				if m.IsBye {
+					continue
+				}
+				for _, pid := range []uint{m.Player1ID, m.Player2ID} {
+					if losses[pid] >= 2 {
+						t.Fatalf("%d players: player %d played after being eliminated", players, pid)
+					}
+				}
+				losses[loserOf(m)]++
+				played++
+			}
+
+			// Everyone but the champion is knocked out, unless the losers bracket side took
+			// a grand final without reset, which leaves the runner-up on one loss as well
+			final := matches[len(matches)-1]
+			if final.Phase != models.PhaseGrandFinal {
+				t.Fatalf("%d players: last match is %s, expected the grand final", players, final.Phase)
+			}
+			survivors := 1
+			if !reset && *final.WinnerID == final.Player2ID {
+				survivors = 2
+			}
+			eliminated := 0
+			for pid := uint(1); pid <= uint(players); pid++ {
+				if losses[pid] >= 2 {
+					eliminated++
+				}
+			}
+			if eliminated != players-survivors {
+				t.Errorf("%d players (reset %v): expected %d eliminated, got %d", players, reset, players-survivors, eliminated)
+			}
+			if played < 2*players-2 || played > 2*players-1 {
+				t.Errorf("%d players (reset %v): unexpected number of played matches %d", players, reset, played)
+			}
+		}
+	}
+}
+
+func TestDoubleEliminationGrandFinalReset(t *testing.T) {
+	// The losers bracket side (player 2 of the grand final) always wins
+	pickLosersSide := func(m models.Match) uint {
+		if m.Phase == models.PhaseGrandFinal {
+			return m.Player2ID
+		}
+		if m.Player1ID < m.Player2ID || m.Player2ID == 0 {
+			return m.Player1ID
+		}
+		return m.Player2ID
+	}
+
+	for _, reset := range []bool{false, true} {
+		finals := 0
+		for _, m := range simulateDoubleElimination(t, 8, reset, pickLosersSide) {
+			if m.Phase == models.PhaseGrandFinal {
+				finals++
+			}
+		}
+		expected := 1
+		if reset {
+			expected = 2
+		}
+		if finals != expected {
+			t.Errorf("reset %v: expected %d grand final matches, got %d", reset, expected, finals)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"bbx_tournament/db"
+	"bbx_tournament/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// eventKeepAlive is how often an idle stream gets a comment line. The stream also re-reads the
+// feed then, in case a wake-up was missed.
+const eventKeepAlive = 15 * time.Second
+
+// eventHub wakes the open streams following a tournament, or a match for scoreboards, when
+// new changes are committed
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers map[uint]map[chan struct{}]bool
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subscribers: make(map[uint]map[chan struct{}]bool)}
+}
+
+// liveEvents is keyed by tournament
+var liveEvents = newEventHub()
+
+// subscribe returns a channel signalled whenever id has changed
+func (h *eventHub) subscribe(id uint) chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan struct{}, 1)
+	if h.subscribers[id] == nil {
+		h.subscribers[id] = make(map[chan struct{}]bool)
+	}
+	h.subscribers[id][ch] = true
+	return ch
+}
+
+func (h *eventHub) unsubscribe(id uint, ch chan struct{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subscribers[id], ch)
+	if len(h.subscribers[id]) == 0 {
+		delete(h.subscribers, id)
+	}
+}
+
+// publish wakes the streams following id. Call it once the transaction making the change has
+// committed.
+func (h *eventHub) publish(id uint) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers[id] {
+		select {
+		case ch <- struct{}{}:
+		default: // Already due a wake-up
+		}
+	}
+}
+
+// recordEvent adds an event to the tournament's feed inside the transaction making the change
+func recordEvent(tx *gorm.DB, tournamentID uint, eventType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.TournamentEvent{TournamentID: tournamentID, Type: eventType, Data: string(data)}).Error
+}
+
+// MatchEvent is the payload of match events
+type MatchEvent struct {
+	MatchID   uint   `json:"match_id"`
+	Phase     string `json:"phase"`
+	Round     int    `json:"round"`
+	Slot      int    `json:"slot"`
+	Player1ID uint   `json:"player1_id"`
+	Player2ID uint   `json:"player2_id"`
+	ScoreP1   int    `json:"score_p1"`
+	ScoreP2   int    `json:"score_p2"`
+	WinnerID  *uint  `json:"winner_id"`
+	IsDraw    bool   `json:"is_draw"`
+	Forfeit   string `json:"forfeit,omitempty"`
+	// Match updated only, the match was removed from the bracket
+	Deleted bool `json:"deleted,omitempty"`
+	// Round scored only
+	MatchRound *models.MatchRound `json:"match_round,omitempty"`
+}
+
+func matchEvent(m models.Match) MatchEvent {
+	return MatchEvent{
+		MatchID:   m.ID,
+		Phase:     m.Phase,
+		Round:     m.Round,
+		Slot:      m.Slot,
+		Player1ID: m.Player1ID,
+		Player2ID: m.Player2ID,
+		ScoreP1:   m.ScoreP1,
+		ScoreP2:   m.ScoreP2,
+		WinnerID:  m.WinnerID,
+		IsDraw:    m.IsDraw,
+		Forfeit:   m.Forfeit,
+	}
+}
+
+// recordMatchDecided reports a match that now has a result
+func recordMatchDecided(tx *gorm.DB, m models.Match) error {
+	if !m.Decided() {
+		return nil
+	}
+	return recordEvent(tx, m.TournamentID, models.EventMatchDecided, matchEvent(m))
+}
+
+// recordMatchUpdated reports a match whose result was cleared or changed after the fact
+func recordMatchUpdated(tx *gorm.DB, m models.Match) error {
+	return recordEvent(tx, m.TournamentID, models.EventMatchUpdated, matchEvent(m))
+}
+
+// StreamTournamentEvents streams the tournament's live feed as Server-Sent Events. A client
+// reconnecting with Last-Event-ID, or the last_event_id query parameter, first gets everything
+// it missed; a new client only gets events from now on.
+func StreamTournamentEvents(w http.ResponseWriter, r *http.Request) {
+	tourIDStr := chi.URLParam(r, "id")
+	tourID, _ := strconv.Atoi(tourIDStr)
+
+	var t models.Tournament
+	if result := db.DB.First(&t, tourID); result.Error != nil {
+		http.Error(w, "Tournament not found", http.StatusNotFound)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	lastIDStr := r.Header.Get("Last-Event-ID")
+	if lastIDStr == "" {
+		lastIDStr = r.URL.Query().Get("last_event_id")
+	}
+	var lastID uint
+	if lastIDStr != "" {
+		id, err := strconv.ParseUint(lastIDStr, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		lastID = uint(id)
+	}
+
+	// Subscribe before reading the feed so nothing committed in between is lost
+	wake := liveEvents.subscribe(t.ID)
+	defer liveEvents.unsubscribe(t.ID, wake)
+
+	if lastIDStr == "" {
+		var latest models.TournamentEvent
+		if err := db.DB.Where("tournament_id = ?", t.ID).Order("id DESC").Limit(1).Find(&latest).Error; err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		lastID = latest.ID
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// send writes every event after lastID
+	send := func() error {
+		var pending []models.TournamentEvent
+		if err := db.DB.Where("tournament_id = ? AND id > ?", t.ID, lastID).Order("id ASC").Find(&pending).Error; err != nil {
+			return err
+		}
+		for _, e := range pending {
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data); err != nil {
+				return err
+			}
+			lastID = e.ID
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	if err := send(); err != nil {
+		return
+	}
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-wake:
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		if err := send(); err != nil {
+			return
+		}
+	}
+}
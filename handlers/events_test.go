@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"bbx_tournament/db"
+	"bbx_tournament/models"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestEventHubPublish(t *testing.T) {
+	hub := newEventHub()
+	a := hub.subscribe(1)
+	b := hub.subscribe(2)
+
+	// Several publishes before the stream reads coalesce into one wake-up
+	hub.publish(1)
+	hub.publish(1)
+	select {
+	case <-a:
+	default:
+		t.Fatal("expected the subscriber to be woken")
+	}
+	select {
+	case <-a:
+		t.Error("expected publishes to coalesce into one wake-up")
+	default:
+	}
+	select {
+	case <-b:
+		t.Error("expected only the tournament's own streams to be woken")
+	default:
+	}
+
+	hub.unsubscribe(1, a)
+	hub.publish(1)
+	select {
+	case <-a:
+		t.Error("expected no wake-up after unsubscribing")
+	default:
+	}
+	if _, ok := hub.subscribers[1]; ok {
+		t.Error("expected the tournament to be dropped with its last subscriber")
+	}
+}
+
+func TestMatchEvent(t *testing.T) {
+	winner := uint(7)
+	m := models.Match{TournamentID: 3, Phase: models.PhaseBracket, Round: 2, Slot: 1, Player1ID: 7, Player2ID: 9, ScoreP1: 4, ScoreP2: 1, WinnerID: &winner}
+	m.ID = 12
+
+	ev := matchEvent(m)
+	if ev.MatchID != 12 || ev.Round != 2 || ev.Slot != 1 || ev.ScoreP1 != 4 || ev.WinnerID == nil || *ev.WinnerID != 7 {
+		t.Errorf("unexpected match event %+v", ev)
+	}
+	if ev.MatchRound != nil {
+		t.Error("expected no round outside round scored events")
+	}
+}
+
+// matchUpdates returns the match updated events of the tournament's feed
+func matchUpdates(t *testing.T, tournamentID uint) []MatchEvent {
+	t.Helper()
+	var events []models.TournamentEvent
+	db.DB.Where("tournament_id = ? AND type = ?", tournamentID, models.EventMatchUpdated).Order("id ASC").Find(&events)
+	updates := make([]MatchEvent, len(events))
+	for i, ev := range events {
+		if err := json.Unmarshal([]byte(ev.Data), &updates[i]); err != nil {
+			t.Fatalf("decode event %q: %v", ev.Data, err)
+		}
+	}
+	return updates
+}
+
+func TestResetAndUndoRecordMatchUpdated(t *testing.T) {
+	openTestDB(t)
+	m, p1, _ := createTestMatch(t)
+	path := fmt.Sprintf("/matches/%d", m.ID)
+
+	req := ScoreRequest{WinnerID: p1.ID, WinType: "Xtreme"}
+	serve(t, UpdateMatchScore, http.MethodPost, "/matches/{id}/score", path+"/score", req)
+	serve(t, UpdateMatchScore, http.MethodPost, "/matches/{id}/score", path+"/score", req)
+	if w := serve(t, UndoMatchRound, http.MethodPost, "/matches/{id}/undo", path+"/undo", nil); w.Code != http.StatusOK {
+		t.Fatalf("undo: %d %s", w.Code, w.Body.String())
+	}
+	if w := serve(t, ResetMatch, http.MethodPost, "/matches/{id}/reset", path+"/reset", nil); w.Code != http.StatusOK {
+		t.Fatalf("reset: %d %s", w.Code, w.Body.String())
+	}
+
+	updates := matchUpdates(t, m.TournamentID)
+	if len(updates) != 2 {
+		t.Fatalf("expected an update for the undo and the reset, got %d", len(updates))
+	}
+	if updates[0].MatchID != m.ID || updates[0].ScoreP1 != 3 {
+		t.Errorf("expected the undo reported at 3-0, got %+v", updates[0])
+	}
+	if updates[1].ScoreP1 != 0 || updates[1].WinnerID != nil {
+		t.Errorf("expected the reset reported with a cleared result, got %+v", updates[1])
+	}
+}
+
+func TestBracketCorrectionRecordsMatchUpdated(t *testing.T) {
+	openTestDB(t)
+	semifinals, final, third := playedSemifinals(t)
+
+	corrected := semifinals[0]
+	winner := corrected.Player2ID
+	corrected.WinnerID = &winner
+	db.DB.Save(&corrected)
+	if _, err := propagateResult(db.DB, corrected, nil); err != nil {
+		t.Fatalf("propagate: %v", err)
+	}
+	updated := map[uint]MatchEvent{}
+	for _, ev := range matchUpdates(t, corrected.TournamentID) {
+		updated[ev.MatchID] = ev
+	}
+	if ev, ok := updated[final.ID]; !ok || ev.Player1ID != corrected.Player2ID || ev.WinnerID != nil {
+		t.Errorf("expected the cleared final reported with its new player, got %+v", ev)
+	}
+	if ev, ok := updated[third.ID]; !ok || ev.Player1ID != corrected.Player1ID {
+		t.Errorf("expected the match for third reported with the new loser, got %+v", ev)
+	}
+
+	if _, err := deleteMatch(db.DB, semifinals[1]); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	deleted := map[uint]bool{}
+	for _, ev := range matchUpdates(t, corrected.TournamentID) {
+		if ev.Deleted {
+			deleted[ev.MatchID] = true
+		}
+	}
+	if len(deleted) != 3 || !deleted[semifinals[1].ID] || !deleted[final.ID] || !deleted[third.ID] {
+		t.Errorf("expected the semifinal and the matches it feeds reported removed, got %v", deleted)
+	}
+}
+
+// sseEvent is one event read off the stream
+type sseEvent struct {
+	id, event, data string
+}
+
+// readEvent reads the next event from an SSE stream, skipping comments
+func readEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+	var ev sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "" && ev.id != "":
+			return ev
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			ev.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			ev.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestStreamReplaysMissedEvents(t *testing.T) {
+	openTestDB(t)
+	tournament := models.Tournament{Name: "Cup"}
+	db.DB.Create(&tournament)
+	var ids []uint
+	for _, eventType := range []string{models.EventParticipantAdded, models.EventStatusChanged, models.EventRoundScored} {
+		if err := recordEvent(db.DB, tournament.ID, eventType, map[string]string{"type": eventType}); err != nil {
+			t.Fatalf("record event: %v", err)
+		}
+		var last models.TournamentEvent
+		db.DB.Last(&last)
+		ids = append(ids, last.ID)
+	}
+
+	r := chi.NewRouter()
+	r.Get("/tournaments/{id}/events", StreamTournamentEvents)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	// The client saw the first event before dropping
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/tournaments/%d/events", server.URL, tournament.ID), nil)
+	req.Header.Set("Last-Event-ID", fmt.Sprint(ids[0]))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer resp.Body.Close()
+	stream := bufio.NewReader(resp.Body)
+
+	for i, expected := range []string{models.EventStatusChanged, models.EventRoundScored} {
+		ev := readEvent(t, stream)
+		if ev.id != fmt.Sprint(ids[i+1]) || ev.event != expected {
+			t.Errorf("replay %d: expected event %d %s, got %s %s", i, ids[i+1], expected, ev.id, ev.event)
+		}
+	}
+
+	// Then it follows the feed live
+	recordEvent(db.DB, tournament.ID, models.EventMatchDecided, map[string]string{"type": "live"})
+	liveEvents.publish(tournament.ID)
+	if ev := readEvent(t, stream); ev.event != models.EventMatchDecided || ev.data != `{"type":"live"}` {
+		t.Errorf("expected the live event next, got %+v", ev)
+	}
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"bbx_tournament/models"
+
+	"gorm.io/gorm"
+)
+
+// linkFeeders records, for each new bracket match, the earlier bracket match each player comes
+// from: the last one they played, taking its winner or, when they lost it, its loser.
+func linkFeeders(matches []models.Match, existing []models.Match) {
+	latest := make(map[uint]models.Match)
+	for _, m := range existing {
+		if !models.IsBracketPhase(m.Phase) {
+			continue
+		}
+		for _, pid := range []uint{m.Player1ID, m.Player2ID} {
+			if prev, ok := latest[pid]; pid != 0 && (!ok || m.ID > prev.ID) {
+				latest[pid] = m
+			}
+		}
+	}
+
+	for i := range matches {
+		m := &matches[i]
+		if !models.IsBracketPhase(m.Phase) {
+			continue
+		}
+		if src, ok := latest[m.Player1ID]; ok && m.Player1ID != 0 {
+			m.Player1FeederID = src.ID
+			m.Player1FeederLoser = lostMatch(src, m.Player1ID)
+		}
+		if src, ok := latest[m.Player2ID]; ok && m.Player2ID != 0 {
+			m.Player2FeederID = src.ID
+			m.Player2FeederLoser = lostMatch(src, m.Player2ID)
+		}
+	}
+}
+
+// BackfillFeeders links the bracket matches of tournaments whose bracket was played before
+// feeder links were stored. Matches are replayed in the order they were created, so each one
+// is linked as it would have been when it was paired.
+func BackfillFeeders(conn *gorm.DB) error {
+	var linked []uint
+	if err := conn.Model(&models.Match{}).Distinct("tournament_id").
+		Where("player1_feeder_id <> 0 OR player2_feeder_id <> 0").Pluck("tournament_id", &linked).Error; err != nil {
+		return err
+	}
+	query := conn.Where("phase IN ?", []string{models.PhaseBracket, models.PhaseThirdPlace, models.PhaseWinnersBracket, models.PhaseLosersBracket, models.PhaseGrandFinal})
+	if len(linked) > 0 {
+		query = query.Where("tournament_id NOT IN ?", linked)
+	}
+	var matches []models.Match
+	if err := query.Order("id ASC").Find(&matches).Error; err != nil {
+		return err
+	}
+
+	byTournament := make(map[uint][]models.Match)
+	for _, m := range matches {
+		byTournament[m.TournamentID] = append(byTournament[m.TournamentID], m)
+	}
+	return conn.Transaction(func(tx *gorm.DB) error {
+		for _, bracket := range byTournament {
+			for i := range bracket {
+				linkFeeders(bracket[i:i+1], bracket[:i])
+				m := bracket[i]
+				if m.Player1FeederID == 0 && m.Player2FeederID == 0 {
+					continue
+				}
+				if err := tx.Model(&models.Match{}).Where("id = ?", m.ID).Updates(map[string]interface{}{
+					"player1_feeder_id":    m.Player1FeederID,
+					"player2_feeder_id":    m.Player2FeederID,
+					"player1_feeder_loser": m.Player1FeederLoser,
+					"player2_feeder_loser": m.Player2FeederLoser,
+				}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// lostMatch reports whether pid lost a decided match
+func lostMatch(m models.Match, pid uint) bool {
+	return m.WinnerID != nil && !m.IsBye && loserOf(m) == pid
+}
+
+// fedPlayer returns who a feeder match sends on to a slot, 0 while it is undecided
+func fedPlayer(feeder models.Match, loser bool) uint {
+	if feeder.WinnerID == nil {
+		return 0
+	}
+	if loser {
+		return loserOf(feeder)
+	}
+	return *feeder.WinnerID
+}
+
+// propagateResult updates the bracket matches fed by m after its result changed. A slot takes
+// the feeder's new winner or loser, or waits empty while the feeder is undecided. A match that
+// was already played with the old player is cleared, and the change carries on down the bracket.
+// Every match changed or removed on the way is reported on the live feed, and returned so their
+// scoreboards can be refreshed once committed.
+func propagateResult(tx *gorm.DB, m models.Match, withdrawn map[uint]bool) ([]uint, error) {
+	var children []models.Match
+	if err := tx.Where("player1_feeder_id = ? OR player2_feeder_id = ?", m.ID, m.ID).Find(&children).Error; err != nil {
+		return nil, err
+	}
+
+	var touched []uint
+	for _, child := range children {
+		// The bracket reset is only played when the losers bracket side took the first grand final
+		if child.Phase == models.PhaseGrandFinal && child.Round == 2 {
+			if m.WinnerID == nil || *m.WinnerID != m.Player2ID {
+				deleted, err := deleteMatch(tx, child)
+				if err != nil {
+					return nil, err
+				}
+				touched = append(touched, deleted...)
+			}
+			continue
+		}
+
+		p1, p2 := child.Player1ID, child.Player2ID
+		if child.Player1FeederID == m.ID {
+			child.Player1ID = fedPlayer(m, child.Player1FeederLoser)
+		}
+		if child.Player2FeederID == m.ID {
+			child.Player2ID = fedPlayer(m, child.Player2FeederLoser)
+		}
+		if child.Player1ID == p1 && child.Player2ID == p2 {
+			continue
+		}
+
+		// Whatever was played involved the wrong player
+		child.ScoreP1, child.ScoreP2 = 0, 0
+		child.WinnerID = nil
+		child.IsDraw, child.TimeCalled = false, false
+		child.Forfeit = ""
+		switch {
+		case child.IsBye && child.Player1ID != 0:
+			winner := child.Player1ID
+			child.WinnerID = &winner
+		case !child.WaitingForPlayers() && (withdrawn[child.Player1ID] || withdrawn[child.Player2ID]):
+			forfeitMatch(&child, withdrawn, models.ForfeitWithdrawal)
+		}
+
+		if err := tx.Where("match_id = ?", child.ID).Delete(&models.MatchRound{}).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Save(&child).Error; err != nil {
+			return nil, err
+		}
+		if err := recordMatchUpdated(tx, child); err != nil {
+			return nil, err
+		}
+		touched = append(touched, child.ID)
+		further, err := propagateResult(tx, child, withdrawn)
+		if err != nil {
+			return nil, err
+		}
+		touched = append(touched, further...)
+	}
+	return touched, nil
+}
+
+// deleteMatch removes a match with its round log, along with everything it feeds, and reports
+// each removal on the live feed. It returns the IDs of the removed matches.
+func deleteMatch(tx *gorm.DB, m models.Match) ([]uint, error) {
+	var children []models.Match
+	if err := tx.Where("player1_feeder_id = ? OR player2_feeder_id = ?", m.ID, m.ID).Find(&children).Error; err != nil {
+		return nil, err
+	}
+	var deleted []uint
+	for _, child := range children {
+		ids, err := deleteMatch(tx, child)
+		if err != nil {
+			return nil, err
+		}
+		deleted = append(deleted, ids...)
+	}
+	if err := tx.Where("match_id = ?", m.ID).Delete(&models.MatchRound{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Delete(&models.Match{}, m.ID).Error; err != nil {
+		return nil, err
+	}
+	removed := matchEvent(m)
+	removed.Deleted = true
+	if err := recordEvent(tx, m.TournamentID, models.EventMatchUpdated, removed); err != nil {
+		return nil, err
+	}
+	return append(deleted, m.ID), nil
+}
+
+// correctBracket carries a changed bracket result down the tree. A finished tournament reopens
+// when that leaves matches to play, otherwise its placements are worked out again. It returns
+// the matches changed or removed further down.
+func correctBracket(tx *gorm.DB, m models.Match) ([]uint, error) {
+	if !models.IsBracketPhase(m.Phase) {
+		return nil, nil
+	}
+
+	var t models.Tournament
+	if err := tx.Preload("TournamentParticipants").First(&t, m.TournamentID).Error; err != nil {
+		return nil, err
+	}
+	touched, err := propagateResult(tx, m, withdrawnPlayers(t.TournamentParticipants))
+	if err != nil {
+		return nil, err
+	}
+	if t.Status != models.StatusFinished {
+		return touched, nil
+	}
+
+	var matches []models.Match
+	if err := tx.Where("tournament_id = ?", t.ID).Find(&matches).Error; err != nil {
+		return nil, err
+	}
+	finished := true
+	for _, bm := range matches {
+		if !bm.Decided() {
+			finished = false
+		}
+	}
+	if finished && t.BracketFormat == models.BracketDoubleElimination {
+		// A corrected grand final may now call for the bracket reset
+		_, finished = nextDoubleEliminationMatches(t, matches)
+	}
+	if finished {
+		return touched, storePlacements(tx, t, matches)
+	}
+
+	t.Status = models.StatusBracketInProgress
+	if err := tx.Model(&models.TournamentParticipant{}).Where("tournament_id = ?", t.ID).Update("placement", 0).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&t).Update("status", t.Status).Error; err != nil {
+		return nil, err
+	}
+	return touched, recordTransition(tx, t, models.StatusFinished, models.ActionCorrection)
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"bbx_tournament/db"
+	"bbx_tournament/models"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestLinkFeeders(t *testing.T) {
+	tournament := models.Tournament{ThirdPlaceMatch: true}
+	semifinals := seedBracketMatches(1, []uint{1, 2, 3, 4}, models.PhaseBracket)
+	for i := range semifinals {
+		semifinals[i].Model = gorm.Model{ID: uint(10 + i)}
+		winner := semifinals[i].Player2ID // Upsets all round
+		semifinals[i].WinnerID = &winner
+	}
+
+	next := generateBracketMatches(1, []uint{*semifinals[0].WinnerID, *semifinals[1].WinnerID}, 2, models.PhaseBracket)
+	next = append(next, thirdPlaceMatch(tournament, semifinals, 2)...)
+	linkFeeders(next, semifinals)
+
+	final, third := next[0], next[1]
+	if final.Player1FeederID != 10 || final.Player2FeederID != 11 || final.Player1FeederLoser || final.Player2FeederLoser {
+		t.Errorf("expected the final fed by the semifinal winners, got %+v", final)
+	}
+	if third.Player1FeederID != 10 || third.Player2FeederID != 11 || !third.Player1FeederLoser || !third.Player2FeederLoser {
+		t.Errorf("expected the match for third fed by the semifinal losers, got %+v", third)
+	}
+
+	// A corrected semifinal sends the other player on, an undecided one nobody
+	corrected := semifinals[0]
+	winner := corrected.Player1ID
+	corrected.WinnerID = &winner
+	if got := fedPlayer(corrected, false); got != corrected.Player1ID {
+		t.Errorf("expected the new winner %d in the final, got %d", corrected.Player1ID, got)
+	}
+	if got := fedPlayer(corrected, true); got != corrected.Player2ID {
+		t.Errorf("expected the new loser %d in the match for third, got %d", corrected.Player2ID, got)
+	}
+	corrected.WinnerID = nil
+	if got := fedPlayer(corrected, false); got != 0 {
+		t.Errorf("expected an empty slot while the semifinal is undecided, got %d", got)
+	}
+}
+
+// playedSemifinals stores a four-player bracket with a match for third, both semifinals won by
+// the top seeds and the final decided on a logged round. It returns the semifinals, the final
+// and the match for third.
+func playedSemifinals(t *testing.T) ([]models.Match, models.Match, models.Match) {
+	t.Helper()
+	tournament := models.Tournament{Name: "Cup", Status: models.StatusBracketInProgress, BracketFormat: models.BracketSingleElimination, ThirdPlaceMatch: true, Rules: models.DefaultRuleSet()}
+	db.DB.Create(&tournament)
+	var seeds []uint
+	for i, nickname := range []string{"Aiger", "Multi", "Bird", "Zonamos"} {
+		p := createTestParticipant(t, nickname)
+		db.DB.Create(&models.TournamentParticipant{TournamentID: tournament.ID, ParticipantID: p.ID, Seed: i + 1})
+		seeds = append(seeds, p.ID)
+	}
+
+	if err := createMatches(db.DB, tournament, seedBracketMatches(tournament.ID, seeds, models.PhaseBracket)); err != nil {
+		t.Fatalf("create semifinals: %v", err)
+	}
+	var semifinals []models.Match
+	db.DB.Where("tournament_id = ?", tournament.ID).Order("slot ASC").Find(&semifinals)
+	for i := range semifinals {
+		winner := semifinals[i].Player1ID
+		semifinals[i].WinnerID = &winner
+		db.DB.Save(&semifinals[i])
+	}
+
+	tournament.Matches = semifinals
+	next := generateBracketMatches(tournament.ID, []uint{*semifinals[0].WinnerID, *semifinals[1].WinnerID}, 2, models.PhaseBracket)
+	next = append(next, thirdPlaceMatch(tournament, semifinals, 2)...)
+	if err := createMatches(db.DB, tournament, next); err != nil {
+		t.Fatalf("create final: %v", err)
+	}
+	var final, third models.Match
+	db.DB.Where("tournament_id = ? AND phase = ?", tournament.ID, models.PhaseBracket).Where("round = 2").First(&final)
+	db.DB.Where("tournament_id = ? AND phase = ?", tournament.ID, models.PhaseThirdPlace).First(&third)
+
+	winner := final.Player1ID
+	final.WinnerID, final.ScoreP1 = &winner, 10
+	db.DB.Save(&final)
+	db.DB.Create(&models.MatchRound{MatchID: final.ID, Sequence: 1, WinnerID: winner, WinType: "Xtreme", Points: 3})
+	return semifinals, final, third
+}
+
+func TestPropagateResult(t *testing.T) {
+	openTestDB(t)
+	semifinals, final, third := playedSemifinals(t)
+
+	// The first semifinal is corrected: its other player goes to the final
+	corrected := semifinals[0]
+	winner := corrected.Player2ID
+	corrected.WinnerID = &winner
+	db.DB.Save(&corrected)
+	touched, err := propagateResult(db.DB, corrected, nil)
+	if err != nil {
+		t.Fatalf("propagate: %v", err)
+	}
+	if !reflect.DeepEqual(touched, []uint{final.ID, third.ID}) && !reflect.DeepEqual(touched, []uint{third.ID, final.ID}) {
+		t.Errorf("expected the final and the match for third returned, got %v", touched)
+	}
+
+	db.DB.First(&final, final.ID)
+	if final.Player1ID != corrected.Player2ID || final.Player2ID != *semifinals[1].WinnerID {
+		t.Errorf("expected the corrected winner in the final, got %d v %d", final.Player1ID, final.Player2ID)
+	}
+	if final.WinnerID != nil || final.ScoreP1 != 0 {
+		t.Errorf("expected the final played with the wrong player cleared, got winner %v score %d", final.WinnerID, final.ScoreP1)
+	}
+	var rounds int64
+	db.DB.Model(&models.MatchRound{}).Where("match_id = ?", final.ID).Count(&rounds)
+	if rounds != 0 {
+		t.Errorf("expected the final's round log cleared, got %d rounds", rounds)
+	}
+	db.DB.First(&third, third.ID)
+	if third.Player1ID != corrected.Player1ID {
+		t.Errorf("expected the new loser %d in the match for third, got %d", corrected.Player1ID, third.Player1ID)
+	}
+
+	// Reset to undecided, the slots wait for a result
+	corrected.WinnerID = nil
+	db.DB.Save(&corrected)
+	if _, err := propagateResult(db.DB, corrected, nil); err != nil {
+		t.Fatalf("propagate: %v", err)
+	}
+	db.DB.First(&final, final.ID)
+	db.DB.First(&third, third.ID)
+	if final.Player1ID != 0 || third.Player1ID != 0 || !final.WaitingForPlayers() {
+		t.Errorf("expected empty slots while the semifinal is undecided, got final %d and third %d", final.Player1ID, third.Player1ID)
+	}
+}
+
+func TestDeleteMatch(t *testing.T) {
+	openTestDB(t)
+	semifinals, final, third := playedSemifinals(t)
+
+	deleted, err := deleteMatch(db.DB, semifinals[0])
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if len(deleted) != 3 || deleted[2] != semifinals[0].ID {
+		t.Errorf("expected the semifinal and the two matches it feeds returned, got %v", deleted)
+	}
+	var left []models.Match
+	db.DB.Find(&left)
+	if len(left) != 1 || left[0].ID != semifinals[1].ID {
+		t.Errorf("expected only the other semifinal left, got %d matches", len(left))
+	}
+	var rounds int64
+	db.DB.Model(&models.MatchRound{}).Where("match_id IN ?", []uint{final.ID, third.ID}).Count(&rounds)
+	if rounds != 0 {
+		t.Errorf("expected the deleted matches' rounds gone, got %d", rounds)
+	}
+}
+
+func TestBackfillFeeders(t *testing.T) {
+	openTestDB(t)
+	_, final, third := playedSemifinals(t)
+
+	// A bracket paired before feeder links were stored
+	db.DB.Session(&gorm.Session{AllowGlobalUpdate: true}).Model(&models.Match{}).Updates(map[string]interface{}{
+		"player1_feeder_id": 0, "player2_feeder_id": 0, "player1_feeder_loser": false, "player2_feeder_loser": false,
+	})
+	if err := BackfillFeeders(db.DB); err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+
+	for _, want := range []models.Match{final, third} {
+		var got models.Match
+		db.DB.First(&got, want.ID)
+		if got.Player1FeederID != want.Player1FeederID || got.Player2FeederID != want.Player2FeederID ||
+			got.Player1FeederLoser != want.Player1FeederLoser || got.Player2FeederLoser != want.Player2FeederLoser {
+			t.Errorf("expected match %d linked as when it was paired, got %+v", want.ID, got)
+		}
+	}
+	if final.Player1FeederID == 0 || !third.Player1FeederLoser {
+		t.Fatalf("expected the original bracket linked, got final %+v and third %+v", final, third)
+	}
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"bbx_tournament/db"
+	"bbx_tournament/models"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// ForfeitRequest names the player giving up a match
+type ForfeitRequest struct {
+	ParticipantID uint   `json:"participant_id"` // Player who forfeits or is disqualified
+	Reason        string `json:"reason"`         // Forfeit (default), Disqualification
+}
+
+// ForfeitMatch awards a match to the opponent of a player who did not show, conceded or was disqualified
+func ForfeitMatch(w http.ResponseWriter, r *http.Request) {
+	matchIDStr := chi.URLParam(r, "id")
+	matchID, _ := strconv.Atoi(matchIDStr)
+
+	var req ForfeitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Reason == "" {
+		req.Reason = models.ForfeitNoShow
+	}
+	if req.Reason != models.ForfeitNoShow && req.Reason != models.ForfeitDisqualified {
+		http.Error(w, "Reason must be Forfeit or Disqualification", http.StatusBadRequest)
+		return
+	}
+
+	var m models.Match
+	if err := db.DB.Preload("Player1").Preload("Player2").First(&m, matchID).Error; err != nil {
+		http.Error(w, "Match not found", http.StatusNotFound)
+		return
+	}
+	if m.IsBye {
+		http.Error(w, "Bye matches cannot be scored", http.StatusBadRequest)
+		return
+	}
+	if _, ok := checkMatchScoring(w, m); !ok {
+		return
+	}
+	if m.WaitingForPlayers() {
+		http.Error(w, "Match is waiting for an earlier bracket result", http.StatusBadRequest)
+		return
+	}
+	if req.ParticipantID != m.Player1ID && req.ParticipantID != m.Player2ID {
+		http.Error(w, "Participant is not in this match", http.StatusBadRequest)
+		return
+	}
+	// A disqualification may overturn a finished match, a plain forfeit may not
+	if m.Decided() && req.Reason != models.ForfeitDisqualified {
+		http.Error(w, "Match already finished", http.StatusBadRequest)
+		return
+	}
+
+	forfeitMatch(&m, map[uint]bool{req.ParticipantID: true}, req.Reason)
+
+	var corrected []uint
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&m).Error; err != nil {
+			return err
+		}
+		if err := recordMatchDecided(tx, m); err != nil {
+			return err
+		}
+		touched, err := correctBracket(tx, m)
+		if err != nil {
+			return err
+		}
+		corrected = touched
+		return rebuildStandings(tx, m.TournamentID)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	liveEvents.publish(m.TournamentID)
+	scoreboards.publish(m.ID)
+	for _, id := range corrected {
+		scoreboards.publish(id)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m)
+}
+
+// WithdrawParticipant takes a player out of the event: every match they have left is forfeited
+// and they no longer count for bracket qualification
+func WithdrawParticipant(w http.ResponseWriter, r *http.Request) {
+	tourIDStr := chi.URLParam(r, "id")
+	tourID, _ := strconv.Atoi(tourIDStr)
+	participantID, _ := strconv.Atoi(chi.URLParam(r, "participantID"))
+
+	var t models.Tournament
+	if result := db.DB.Preload("Matches").Preload("TournamentParticipants").First(&t, tourID); result.Error != nil {
+		http.Error(w, "Tournament not found", http.StatusNotFound)
+		return
+	}
+	if !checkTransition(w, t, models.ActionWithdraw) {
+		return
+	}
+
+	var tp models.TournamentParticipant
+	if err := db.DB.Where("tournament_id = ? AND participant_id = ?", t.ID, participantID).First(&tp).Error; err != nil {
+		http.Error(w, "Participant not in tournament", http.StatusNotFound)
+		return
+	}
+	if tp.Withdrawn {
+		http.Error(w, "Participant already withdrawn", http.StatusBadRequest)
+		return
+	}
+
+	var forfeited []uint
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&tp).Update("withdrawn", true).Error; err != nil {
+			return err
+		}
+
+		var pending []models.Match
+		if err := tx.Where("tournament_id = ? AND (player1_id = ? OR player2_id = ?)", t.ID, participantID, participantID).
+			Find(&pending).Error; err != nil {
+			return err
+		}
+		withdrawn := withdrawnPlayers(t.TournamentParticipants)
+		withdrawn[tp.ParticipantID] = true
+		for i := range pending {
+			if pending[i].Decided() || pending[i].IsBye || pending[i].WaitingForPlayers() {
+				continue
+			}
+			forfeitMatch(&pending[i], withdrawn, models.ForfeitWithdrawal)
+			if err := tx.Save(&pending[i]).Error; err != nil {
+				return err
+			}
+			if err := recordMatchDecided(tx, pending[i]); err != nil {
+				return err
+			}
+			corrected, err := correctBracket(tx, pending[i])
+			if err != nil {
+				return err
+			}
+			forfeited = append(forfeited, pending[i].ID)
+			forfeited = append(forfeited, corrected...)
+		}
+		if err := recordTransition(tx, t, t.Status, models.ActionWithdraw); err != nil {
+			return err
+		}
+		return rebuildStandings(tx, t.ID)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	liveEvents.publish(t.ID)
+	for _, id := range forfeited {
+		scoreboards.publish(id)
+	}
+
+	db.DB.Preload("Participant").First(&tp, tp.ID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tp)
+}
+
+// forfeitMatch gives the match to whichever side did not forfeit. When both did, a bracket
+// match still sends Player1 on so the bracket can complete, a group match is lost by both.
+func forfeitMatch(m *models.Match, forfeiting map[uint]bool, reason string) {
+	m.Forfeit = reason
+	m.IsDraw = false
+	m.WinnerID = nil
+	switch {
+	case !forfeiting[m.Player1ID]:
+		pid := m.Player1ID
+		m.WinnerID = &pid
+	case !forfeiting[m.Player2ID]:
+		pid := m.Player2ID
+		m.WinnerID = &pid
+	case models.IsBracketPhase(m.Phase):
+		pid := m.Player1ID
+		m.WinnerID = &pid
+	}
+}
+
+// withdrawnPlayers returns the participants who left the event
+func withdrawnPlayers(participants []models.TournamentParticipant) map[uint]bool {
+	withdrawn := make(map[uint]bool)
+	for _, tp := range participants {
+		if tp.Withdrawn {
+			withdrawn[tp.ParticipantID] = true
+		}
+	}
+	return withdrawn
+}
+
+// createMatches stores newly paired matches, linked to the bracket matches feeding them, and
+// forfeits straight away any that involve a withdrawn player. Standings are rebuilt when a match
+// starts out decided. t.Matches must hold the existing matches.
+func createMatches(tx *gorm.DB, t models.Tournament, matches []models.Match) error {
+	if len(matches) == 0 {
+		return nil
+	}
+	linkFeeders(matches, t.Matches)
+	withdrawn := withdrawnPlayers(t.TournamentParticipants)
+	decided := false
+	for i := range matches {
+		m := &matches[i]
+		if !m.IsBye && (withdrawn[m.Player1ID] || withdrawn[m.Player2ID]) {
+			forfeitMatch(m, withdrawn, models.ForfeitWithdrawal)
+		}
+		decided = decided || m.Decided()
+	}
+	if err := tx.Create(&matches).Error; err != nil {
+		return err
+	}
+	// Byes and forfeits count in the standings straight away
+	if !decided {
+		return nil
+	}
+	for _, m := range matches {
+		if m.Forfeit == "" {
+			continue
+		}
+		if err := recordMatchDecided(tx, m); err != nil {
+			return err
+		}
+	}
+	return rebuildStandings(tx, t.ID)
+}
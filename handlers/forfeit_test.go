@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"bbx_tournament/db"
+	"bbx_tournament/models"
+	"testing"
+)
+
+func TestForfeitsCountAsResultsOnly(t *testing.T) {
+	rules := models.DefaultRuleSet()
+	participants := []models.TournamentParticipant{
+		{ParticipantID: 1, Group: "A"},
+		{ParticipantID: 2, Group: "A", Withdrawn: true},
+		{ParticipantID: 3, Group: "A"},
+	}
+	dq := models.Match{Phase: "A", Player1ID: 1, Player2ID: 3, ScoreP1: 6, ScoreP2: 3, Rounds: []models.MatchRound{
+		{WinnerID: 1, WinType: "Xtreme"},
+		{WinnerID: 3, WinType: "Xtreme"},
+		{WinnerID: 1, WinType: "Xtreme"},
+	}}
+	forfeitMatch(&dq, map[uint]bool{1: true}, models.ForfeitDisqualified)
+	walkover := models.Match{Phase: "A", Player1ID: 1, Player2ID: 2}
+	forfeitMatch(&walkover, map[uint]bool{2: true}, models.ForfeitWithdrawal)
+	matches := []models.Match{dq, walkover, {Phase: "A", Player1ID: 2, Player2ID: 3}}
+	forfeitMatch(&matches[2], map[uint]bool{2: true}, models.ForfeitWithdrawal)
+
+	participants = tallyStandings(participants, matches, rules)
+	rankStandings(participants, matches, rules)
+
+	expected := map[uint]struct{ wins, losses, rank int }{
+		1: {1, 1, 2},
+		2: {0, 2, 3},
+		3: {2, 0, 1},
+	}
+	for _, tp := range participants {
+		e := expected[tp.ParticipantID]
+		if tp.Wins != e.wins || tp.Losses != e.losses || tp.Rank != e.rank {
+			t.Errorf("player %d: expected %d-%d rank %d, got %d-%d rank %d", tp.ParticipantID, e.wins, e.losses, e.rank, tp.Wins, tp.Losses, tp.Rank)
+		}
+		if tp.XtremeFinishes != 0 || tp.ScoreFor != 0 {
+			t.Errorf("player %d: forfeited rounds must not count, got %d Xtreme and %d scored", tp.ParticipantID, tp.XtremeFinishes, tp.ScoreFor)
+		}
+	}
+
+	// Player 2 is out of qualification even when every place qualifies
+	for _, seed := range seedQualifiers(participants, 3) {
+		if seed == 2 {
+			t.Errorf("withdrawn player was seeded")
+		}
+	}
+}
+
+func TestForfeitMatchBothSides(t *testing.T) {
+	both := map[uint]bool{1: true, 2: true}
+
+	group := models.Match{Phase: "A", Player1ID: 1, Player2ID: 2}
+	forfeitMatch(&group, both, models.ForfeitWithdrawal)
+	if group.WinnerID != nil || !group.Decided() {
+		t.Errorf("expected a double forfeit with no winner, got %v", group.WinnerID)
+	}
+
+	bracket := models.Match{Phase: models.PhaseBracket, Player1ID: 1, Player2ID: 2}
+	forfeitMatch(&bracket, both, models.ForfeitWithdrawal)
+	if bracket.WinnerID == nil || *bracket.WinnerID != 1 {
+		t.Errorf("expected player 1 to be moved on in the bracket, got %v", bracket.WinnerID)
+	}
+}
+
+func TestSwissSkipsWithdrawnPlayers(t *testing.T) {
+	participants := []models.TournamentParticipant{
+		{ParticipantID: 1, Group: models.PhaseSwiss},
+		{ParticipantID: 2, Group: models.PhaseSwiss, Withdrawn: true},
+		{ParticipantID: 3, Group: models.PhaseSwiss},
+		{ParticipantID: 4, Group: models.PhaseSwiss},
+		{ParticipantID: 5, Group: models.PhaseSwiss},
+	}
+	for _, m := range nextSwissRound(1, participants, nil) {
+		if m.IsBye {
+			t.Errorf("expected no bye with four active players, got one for %d", m.Player1ID)
+		}
+		if m.Player1ID == 2 || m.Player2ID == 2 {
+			t.Errorf("withdrawn player was paired")
+		}
+	}
+}
+
+func TestCreateMatchesCountsSwissBye(t *testing.T) {
+	openTestDB(t)
+	tournament := models.Tournament{Name: "Swiss", Status: models.StatusInProgress, Format: models.FormatSwiss, Rules: models.DefaultRuleSet()}
+	db.DB.Create(&tournament)
+	for _, nickname := range []string{"Aiger", "Multi", "Bird"} {
+		p := createTestParticipant(t, nickname)
+		db.DB.Create(&models.TournamentParticipant{TournamentID: tournament.ID, ParticipantID: p.ID, Group: models.PhaseSwiss})
+	}
+	db.DB.Preload("TournamentParticipants").First(&tournament, tournament.ID)
+
+	round := nextSwissRound(tournament.ID, tournament.TournamentParticipants, nil)
+	if err := createMatches(db.DB, tournament, round); err != nil {
+		t.Fatalf("create matches: %v", err)
+	}
+
+	var bye models.Match
+	if err := db.DB.Where("is_bye = ?", true).First(&bye).Error; err != nil {
+		t.Fatalf("expected a bye with three players: %v", err)
+	}
+	var tp models.TournamentParticipant
+	db.DB.Where("participant_id = ?", bye.Player1ID).First(&tp)
+	if tp.Wins != 1 || tp.Points != tournament.Rules.MatchWinPoints {
+		t.Errorf("expected the bye counted as a win straight away, got %d wins and %d points", tp.Wins, tp.Points)
+	}
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"bbx_tournament/db"
+	"bbx_tournament/models"
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+const (
+	defaultGroupSize          = 10
+	defaultQualifiersPerGroup = 4
+)
+
+// GroupSettings is the optional payload of GenerateGroups; unset fields keep the tournament's values
+type GroupSettings struct {
+	GroupSize          *int     `json:"group_size"`
+	GroupCount         *int     `json:"group_count"`
+	QualifiersPerGroup *int     `json:"qualifiers_per_group"`
+	GroupSeeding       *string  `json:"group_seeding"`
+	DrawSeed           *int64   `json:"draw_seed"`          // Random: send a new seed to redraw
+	SeedTournamentID   *uint    `json:"seed_tournament_id"` // Results
+	ManualGroups       [][]uint `json:"manual_groups"`      // Manual: participant IDs per group, in label order
+}
+
+// apply copies the provided settings onto the tournament
+func (s GroupSettings) apply(t *models.Tournament) {
+	if s.GroupSize != nil {
+		t.GroupSize = *s.GroupSize
+	}
+	if s.GroupCount != nil {
+		t.GroupCount = *s.GroupCount
+	}
+	if s.QualifiersPerGroup != nil {
+		t.QualifiersPerGroup = *s.QualifiersPerGroup
+	}
+	if s.GroupSeeding != nil {
+		t.GroupSeeding = *s.GroupSeeding
+	}
+	if s.DrawSeed != nil {
+		t.DrawSeed = *s.DrawSeed
+	}
+	if s.SeedTournamentID != nil {
+		t.SeedTournamentID = *s.SeedTournamentID
+	}
+}
+
+// validGroupSeeding reports whether mode is a known seeding mode, empty meaning Joined
+func validGroupSeeding(mode string) bool {
+	switch mode {
+	case "", models.SeedingJoined, models.SeedingRandom, models.SeedingRating, models.SeedingResults, models.SeedingManual:
+		return true
+	}
+	return false
+}
+
+// qualifiersPerGroup returns how many players of each group advance to the bracket
+func qualifiersPerGroup(t models.Tournament) int {
+	if t.QualifiersPerGroup > 0 {
+		return t.QualifiersPerGroup
+	}
+	return defaultQualifiersPerGroup
+}
+
+// planGroups works out how many groups n players are split into and checks that every
+// group can be played and still send its qualifiers to the bracket
+func planGroups(t models.Tournament, n int) (int, error) {
+	if t.GroupSize < 0 || t.GroupCount < 0 || t.QualifiersPerGroup < 0 {
+		return 0, fmt.Errorf("Group settings cannot be negative")
+	}
+	if t.GroupSize > 0 && t.GroupCount > 0 {
+		return 0, fmt.Errorf("Set either group_size or group_count, not both")
+	}
+
+	if t.Format == models.FormatSwiss {
+		return 1, nil
+	}
+
+	numGroups := 1
+	switch {
+	case t.GroupCount > 0:
+		numGroups = t.GroupCount
+	case t.GroupSize > 0:
+		if t.GroupSize < 2 {
+			return 0, fmt.Errorf("group_size must be at least 2")
+		}
+		numGroups = (n + t.GroupSize - 1) / t.GroupSize
+	default:
+		// Target ~10 players per group
+		numGroups = (n + defaultGroupSize - 1) / defaultGroupSize
+	}
+
+	smallest := n / numGroups
+	if smallest < 2 {
+		return 0, fmt.Errorf("%d players cannot fill %d groups of at least 2", n, numGroups)
+	}
+	// The default takes up to 4 from each group, an explicit count must be met by every group
+	if q := t.QualifiersPerGroup; q > smallest {
+		return 0, fmt.Errorf("%d qualifiers per group, but the smallest group only has %d players", q, smallest)
+	}
+
+	return numGroups, nil
+}
+
+// groupName returns spreadsheet-style labels: A..Z, then AA, AB, ...
+func groupName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+// seedStrength is what the snake draft orders players by, best first
+type seedStrength struct {
+	ParticipantID uint
+	Points        int
+	Wins          int
+}
+
+// loadSeedStrength reads the standings the snake draft is based on: league totals from
+// every other event for Rating, the referenced event for Results
+func loadSeedStrength(t models.Tournament) (map[uint]seedStrength, error) {
+	query := db.DB.Model(&models.TournamentParticipant{}).
+		Select("participant_id, sum(points) as points, sum(wins) as wins").
+		Group("participant_id")
+	if t.GroupSeeding == models.SeedingResults {
+		if t.SeedTournamentID == 0 || t.SeedTournamentID == t.ID {
+			return nil, fmt.Errorf("Results seeding needs seed_tournament_id of a previous tournament")
+		}
+		var previous models.Tournament
+		if err := db.DB.First(&previous, t.SeedTournamentID).Error; err != nil {
+			return nil, fmt.Errorf("Seed tournament %d not found", t.SeedTournamentID)
+		}
+		query = query.Where("tournament_id = ?", t.SeedTournamentID)
+	} else {
+		query = query.Where("tournament_id <> ?", t.ID)
+	}
+
+	var rows []seedStrength
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	strength := make(map[uint]seedStrength, len(rows))
+	for _, row := range rows {
+		strength[row.ParticipantID] = row
+	}
+	return strength, nil
+}
+
+// drawGroups splits participants into numGroups groups and returns their indices per group.
+// Random shuffles with t.DrawSeed, Rating and Results snake by strength, anything else deals
+// in sign-up order.
+func drawGroups(t models.Tournament, participants []models.TournamentParticipant, numGroups int, strength map[uint]seedStrength) [][]int {
+	order := make([]int, len(participants))
+	for i := range order {
+		order[i] = i
+	}
+	// Sign-up order, independent of how the rows were loaded
+	sort.SliceStable(order, func(a, b int) bool {
+		return participants[order[a]].ID < participants[order[b]].ID
+	})
+
+	switch t.GroupSeeding {
+	case models.SeedingRandom:
+		rng := rand.New(rand.NewSource(t.DrawSeed))
+		rng.Shuffle(len(order), func(a, b int) { order[a], order[b] = order[b], order[a] })
+	case models.SeedingRating, models.SeedingResults:
+		sort.SliceStable(order, func(a, b int) bool {
+			sa, sb := strength[participants[order[a]].ParticipantID], strength[participants[order[b]].ParticipantID]
+			if sa.Points != sb.Points {
+				return sa.Points > sb.Points
+			}
+			return sa.Wins > sb.Wins
+		})
+		return snakeDraft(order, numGroups)
+	}
+
+	groups := make([][]int, numGroups)
+	for i, idx := range order {
+		groups[i%numGroups] = append(groups[i%numGroups], idx)
+	}
+	return groups
+}
+
+// snakeDraft deals players ranked best first back and forth across the groups: A B C C B A A ...
+func snakeDraft(order []int, numGroups int) [][]int {
+	groups := make([][]int, numGroups)
+	for i, idx := range order {
+		g := i % numGroups
+		if (i/numGroups)%2 == 1 {
+			g = numGroups - 1 - g
+		}
+		groups[g] = append(groups[g], idx)
+	}
+	return groups
+}
+
+// manualGroups maps the organizer's groups of participant IDs onto participant indices,
+// requiring every participant exactly once and groups big enough to play and qualify
+func manualGroups(t models.Tournament, participants []models.TournamentParticipant, manual [][]uint) ([][]int, error) {
+	if len(manual) == 0 {
+		return nil, fmt.Errorf("Manual seeding needs manual_groups")
+	}
+	index := make(map[uint]int, len(participants))
+	for i, tp := range participants {
+		index[tp.ParticipantID] = i
+	}
+
+	seen := make(map[uint]bool)
+	groups := make([][]int, len(manual))
+	for g, ids := range manual {
+		if len(ids) < 2 {
+			return nil, fmt.Errorf("Group %s needs at least 2 players", groupName(g))
+		}
+		if t.QualifiersPerGroup > len(ids) {
+			return nil, fmt.Errorf("%d qualifiers per group, but group %s only has %d players", t.QualifiersPerGroup, groupName(g), len(ids))
+		}
+		for _, pid := range ids {
+			idx, ok := index[pid]
+			if !ok {
+				return nil, fmt.Errorf("Participant %d is not in this tournament", pid)
+			}
+			if seen[pid] {
+				return nil, fmt.Errorf("Participant %d is in more than one group", pid)
+			}
+			seen[pid] = true
+			groups[g] = append(groups[g], idx)
+		}
+	}
+	if len(seen) != len(participants) {
+		return nil, fmt.Errorf("%d of %d participants are not assigned to a group", len(participants)-len(seen), len(participants))
+	}
+	return groups, nil
+}
+
+// spreadClubs swaps players between groups until as few club-mates as possible share a group.
+// Swaps between players drawn in the same row are tried first so the snake or random draw
+// keeps its balance, other swaps only when those cannot improve it.
+func spreadClubs(groups [][]int, participants []models.TournamentParticipant) {
+	club := func(idx int) string { return participants[idx].Participant.Club }
+
+	// clashes counts pairs of club-mates in a group
+	clashes := func(group []int) int {
+		count := make(map[string]int)
+		total := 0
+		for _, idx := range group {
+			if c := club(idx); c != "" {
+				total += count[c]
+				count[c]++
+			}
+		}
+		return total
+	}
+
+	trySwap := func(a, i, b, j int) bool {
+		if club(groups[a][i]) == club(groups[b][j]) {
+			return false
+		}
+		before := clashes(groups[a]) + clashes(groups[b])
+		groups[a][i], groups[b][j] = groups[b][j], groups[a][i]
+		if clashes(groups[a])+clashes(groups[b]) < before {
+			return true
+		}
+		groups[a][i], groups[b][j] = groups[b][j], groups[a][i]
+		return false
+	}
+
+	for _, sameRow := range []bool{true, false} {
+		for improved := true; improved; {
+			improved = false
+			for a := range groups {
+				for b := a + 1; b < len(groups); b++ {
+					for i := range groups[a] {
+						for j := range groups[b] {
+							if sameRow && i != j {
+								continue
+							}
+							if trySwap(a, i, b, j) {
+								improved = true
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}
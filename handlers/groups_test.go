@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"bbx_tournament/models"
+	"testing"
+)
+
+func TestGroupNameExtendsPastZ(t *testing.T) {
+	cases := map[int]string{0: "A", 7: "H", 8: "I", 25: "Z", 26: "AA", 27: "AB", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"}
+	for index, expected := range cases {
+		if got := groupName(index); got != expected {
+			t.Errorf("groupName(%d) = %q, expected %q", index, got, expected)
+		}
+	}
+}
+
+func TestPlanGroups(t *testing.T) {
+	cases := []struct {
+		name      string
+		t         models.Tournament
+		players   int
+		groups    int
+		expectErr bool
+	}{
+		{"default small", models.Tournament{}, 8, 1, false},
+		{"default large", models.Tournament{}, 25, 3, false},
+		{"group size", models.Tournament{GroupSize: 4}, 18, 5, false},
+		{"default qualifiers in small groups", models.Tournament{GroupSize: 3}, 9, 3, false},
+		{"group count", models.Tournament{GroupCount: 12}, 48, 12, false},
+		{"swiss ignores sizing", models.Tournament{Format: models.FormatSwiss, GroupCount: 4}, 20, 1, false},
+		{"both set", models.Tournament{GroupSize: 4, GroupCount: 2}, 8, 0, true},
+		{"groups of one", models.Tournament{GroupCount: 5}, 8, 0, true},
+		{"group size of one", models.Tournament{GroupSize: 1}, 8, 0, true},
+		{"too many qualifiers", models.Tournament{GroupCount: 3, QualifiersPerGroup: 4}, 11, 0, true},
+		{"qualifiers fit", models.Tournament{GroupCount: 3, QualifiersPerGroup: 3}, 11, 3, false},
+		{"negative", models.Tournament{QualifiersPerGroup: -1}, 8, 0, true},
+	}
+	for _, c := range cases {
+		groups, err := planGroups(c.t, c.players)
+		if c.expectErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %d groups", c.name, groups)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		} else if groups != c.groups {
+			t.Errorf("%s: expected %d groups, got %d", c.name, c.groups, groups)
+		}
+	}
+}
+
+func joinedParticipants(n int) []models.TournamentParticipant {
+	participants := make([]models.TournamentParticipant, n)
+	for i := range participants {
+		participants[i].ID = uint(i + 1)
+		participants[i].ParticipantID = uint(i + 1)
+	}
+	return participants
+}
+
+func TestSnakeDraftBalancesGroups(t *testing.T) {
+	participants := joinedParticipants(12)
+	// Player 12 is the strongest, player 1 the weakest
+	strength := make(map[uint]seedStrength)
+	for _, tp := range participants {
+		strength[tp.ParticipantID] = seedStrength{ParticipantID: tp.ParticipantID, Points: int(tp.ParticipantID) * 10}
+	}
+
+	tournament := models.Tournament{GroupSeeding: models.SeedingRating}
+	groups := drawGroups(tournament, participants, 3, strength)
+
+	expected := [][]uint{{12, 7, 6, 1}, {11, 8, 5, 2}, {10, 9, 4, 3}}
+	for g, indices := range groups {
+		for i, idx := range indices {
+			if participants[idx].ParticipantID != expected[g][i] {
+				t.Fatalf("group %d: expected %v, got indices %v", g, expected[g], indices)
+			}
+		}
+	}
+}
+
+func TestRandomDrawIsReproducible(t *testing.T) {
+	participants := joinedParticipants(16)
+	tournament := models.Tournament{GroupSeeding: models.SeedingRandom, DrawSeed: 42}
+
+	first := drawGroups(tournament, participants, 4, nil)
+	// Load order must not matter
+	reversed := make([]models.TournamentParticipant, len(participants))
+	for i, tp := range participants {
+		reversed[len(participants)-1-i] = tp
+	}
+	second := drawGroups(tournament, reversed, 4, nil)
+
+	for g := range first {
+		for i := range first[g] {
+			if participants[first[g][i]].ParticipantID != reversed[second[g][i]].ParticipantID {
+				t.Fatalf("group %d differs between draws with the same seed", g)
+			}
+		}
+	}
+}
+
+func TestManualGroupsValidation(t *testing.T) {
+	participants := joinedParticipants(6)
+	tournament := models.Tournament{GroupSeeding: models.SeedingManual}
+
+	groups, err := manualGroups(tournament, participants, [][]uint{{1, 4, 5}, {2, 3, 6}})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(groups) != 2 || participants[groups[1][2]].ParticipantID != 6 {
+		t.Errorf("unexpected groups %v", groups)
+	}
+
+	invalid := map[string][][]uint{
+		"missing player":  {{1, 2, 3}, {4, 5}},
+		"duplicate":       {{1, 2, 3}, {3, 4, 5, 6}},
+		"unknown player":  {{1, 2, 3}, {4, 5, 6, 7}},
+		"group of one":    {{1}, {2, 3, 4, 5, 6}},
+		"no groups given": nil,
+	}
+	for name, manual := range invalid {
+		if _, err := manualGroups(tournament, participants, manual); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestSpreadClubs(t *testing.T) {
+	participants := joinedParticipants(12)
+	// Dealt in sign-up order, players 1, 4, 7 and 10 would all land in group A
+	for _, idx := range []int{0, 3, 6, 9} {
+		participants[idx].Participant.Club = "Hobby Shop"
+	}
+	for _, idx := range []int{1, 2, 4} {
+		participants[idx].Participant.Club = "Card Store"
+	}
+
+	groups := drawGroups(models.Tournament{}, participants, 4, nil)
+	spreadClubs(groups, participants)
+
+	for g, group := range groups {
+		if len(group) != 3 {
+			t.Errorf("group %d has %d players, expected 3", g, len(group))
+		}
+		clubs := make(map[string]bool)
+		for _, idx := range group {
+			club := participants[idx].Participant.Club
+			if club != "" && clubs[club] {
+				t.Errorf("group %d has two players from %s", g, club)
+			}
+			clubs[club] = true
+		}
+	}
+}
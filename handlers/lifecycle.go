@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"bbx_tournament/db"
+	"bbx_tournament/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// lifecycleGuards explain why an action the current stage permits cannot run yet, "" when it
+// can. They read the tournament with its participants and matches loaded.
+var lifecycleGuards = map[models.TournamentAction]func(t models.Tournament) string{
+	models.ActionGenerateGroups: func(t models.Tournament) string {
+		if len(t.TournamentParticipants) < 2 {
+			return "Not enough participants"
+		}
+		return ""
+	},
+	models.ActionAdvance: func(t models.Tournament) string {
+		for _, m := range t.Matches {
+			if !m.Decided() {
+				return "Current phase matches are not all finished"
+			}
+		}
+		// Groups must have settled who takes the last qualifying place
+		if t.Status == models.StatusInProgress && t.Format != models.FormatSwiss {
+			if group := unresolvedCut(t.TournamentParticipants, t.Matches, t.Rules, qualifiersPerGroup(t)); group != "" {
+				return "Tie for the last qualifying place in " + group + ", record a playoff or coin flip first"
+			}
+		}
+		return ""
+	},
+}
+
+// allowedActions lists the actions the tournament's stage permits and whose guards pass
+func allowedActions(t models.Tournament) []models.TournamentAction {
+	allowed := []models.TournamentAction{}
+	for _, action := range t.Status.Actions() {
+		if guard := lifecycleGuards[action]; guard == nil || guard(t) == "" {
+			allowed = append(allowed, action)
+		}
+	}
+	return allowed
+}
+
+// checkTransition answers 409 with the current stage and the allowed actions when action
+// cannot be taken now. t must have its participants and matches loaded.
+func checkTransition(w http.ResponseWriter, t models.Tournament, action models.TournamentAction) bool {
+	reason := fmt.Sprintf("%s is not allowed while the tournament is %s", action, t.Status)
+	for _, a := range t.Status.Actions() {
+		if a == action {
+			reason = ""
+			if guard := lifecycleGuards[action]; guard != nil {
+				reason = guard(t)
+			}
+			break
+		}
+	}
+	if reason == "" {
+		return true
+	}
+	writeTransitionConflict(w, t, reason)
+	return false
+}
+
+// checkMatchScoring loads the match's tournament and answers like checkTransition unless the
+// match can be changed now: group and Swiss matches while they are played, bracket matches
+// while the bracket is, or once finished as a correction. It returns the rules to score by.
+func checkMatchScoring(w http.ResponseWriter, m models.Match) (models.RuleSet, bool) {
+	var t models.Tournament
+	if err := db.DB.Preload("Matches").Preload("TournamentParticipants").First(&t, m.TournamentID).Error; err != nil {
+		http.Error(w, "Tournament not found", http.StatusNotFound)
+		return models.RuleSet{}, false
+	}
+	if !checkTransition(w, t, models.ActionScoreMatch) {
+		return models.RuleSet{}, false
+	}
+
+	if bracket := models.IsBracketPhase(m.Phase); bracket == (t.Status == models.StatusInProgress) {
+		stage := "Group and Swiss"
+		if bracket {
+			stage = "Bracket"
+		}
+		writeTransitionConflict(w, t, fmt.Sprintf("%s matches cannot be changed while the tournament is %s", stage, t.Status))
+		return models.RuleSet{}, false
+	}
+	return t.Rules, true
+}
+
+// writeTransitionConflict answers 409 with why the request cannot run at the current stage
+func writeTransitionConflict(w http.ResponseWriter, t models.Tournament, reason string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":           reason,
+		"status":          t.Status,
+		"allowed_actions": allowedActions(t),
+	})
+}
+
+// recordTransition logs an action that moved the tournament from one stage to t.Status, and
+// reports it on the live feed when it advanced the tournament or changed its stage
+func recordTransition(tx *gorm.DB, t models.Tournament, from models.TournamentStatus, action models.TournamentAction) error {
+	if !models.CanTransition(from, action, t.Status) {
+		return fmt.Errorf("%s cannot move a tournament from %s to %s", action, from, t.Status)
+	}
+	transition := models.TournamentTransition{
+		TournamentID: t.ID,
+		Action:       action,
+		From:         from,
+		To:           t.Status,
+	}
+	if err := tx.Create(&transition).Error; err != nil {
+		return err
+	}
+
+	switch {
+	case action == models.ActionAdvance:
+		return recordEvent(tx, t.ID, models.EventPhaseAdvanced, transition)
+	case from != t.Status:
+		return recordEvent(tx, t.ID, models.EventStatusChanged, transition)
+	}
+	return nil
+}
+
+// GetTournamentLifecycle returns the tournament's stage, what can be done next and the history of actions taken
+func GetTournamentLifecycle(w http.ResponseWriter, r *http.Request) {
+	tourIDStr := chi.URLParam(r, "id")
+	tourID, _ := strconv.Atoi(tourIDStr)
+
+	var t models.Tournament
+	if result := db.DB.Preload("Matches").Preload("TournamentParticipants").First(&t, tourID); result.Error != nil {
+		http.Error(w, "Tournament not found", http.StatusNotFound)
+		return
+	}
+
+	var history []models.TournamentTransition
+	if err := db.DB.Where("tournament_id = ?", t.ID).Order("id ASC").Find(&history).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":          t.Status,
+		"allowed_actions": allowedActions(t),
+		"transitions":     history,
+	})
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"bbx_tournament/db"
+	"bbx_tournament/models"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestAllowedActions(t *testing.T) {
+	winner := uint(1)
+	decided := models.Match{Player1ID: 1, Player2ID: 2, Phase: "Group A", WinnerID: &winner}
+	pending := models.Match{Player1ID: 3, Player2ID: 4, Phase: "Group A"}
+	players := []models.TournamentParticipant{
+		{ParticipantID: 1, Group: "Group A"},
+		{ParticipantID: 2, Group: "Group A"},
+	}
+
+	tests := []struct {
+		name     string
+		t        models.Tournament
+		expected []models.TournamentAction
+	}{
+		{
+			"Sign-ups still open",
+			models.Tournament{Status: models.StatusCreated, TournamentParticipants: players[:1]},
+			[]models.TournamentAction{models.ActionAddParticipant, models.ActionUpdateRules, models.ActionWithdraw, models.ActionReset},
+		},
+		{
+			"Enough players to draw groups",
+			models.Tournament{Status: models.StatusCreated, TournamentParticipants: players},
+			[]models.TournamentAction{models.ActionAddParticipant, models.ActionUpdateRules, models.ActionGenerateGroups, models.ActionWithdraw, models.ActionReset},
+		},
+		{
+			"Group matches left to play",
+			models.Tournament{Status: models.StatusInProgress, TournamentParticipants: players, Matches: []models.Match{decided, pending}},
+			[]models.TournamentAction{models.ActionRecordTiebreak, models.ActionWithdraw, models.ActionScoreMatch, models.ActionReset},
+		},
+		{
+			"Groups complete",
+			models.Tournament{Status: models.StatusInProgress, TournamentParticipants: players, Matches: []models.Match{decided}},
+			[]models.TournamentAction{models.ActionRecordTiebreak, models.ActionWithdraw, models.ActionScoreMatch, models.ActionAdvance, models.ActionReset},
+		},
+		{
+			"Finished",
+			models.Tournament{Status: models.StatusFinished},
+			[]models.TournamentAction{models.ActionScoreMatch, models.ActionRollback, models.ActionCorrection, models.ActionReset},
+		},
+		{
+			"Unknown stage",
+			models.Tournament{Status: "Paused"},
+			[]models.TournamentAction{},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := allowedActions(tt.t); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	if !models.CanTransition(models.StatusBracketInProgress, models.ActionAdvance, models.StatusFinished) {
+		t.Error("expected advancing a bracket to be able to finish it")
+	}
+	if models.CanTransition(models.StatusCreated, models.ActionAdvance, models.StatusFinished) {
+		t.Error("expected a tournament without matches not to be finishable")
+	}
+	if models.CanTransition(models.StatusInProgress, models.ActionRollback, models.StatusGroupsGenerated) {
+		t.Error("expected rollback to keep group results")
+	}
+}
+
+func TestAdvanceIsAtomic(t *testing.T) {
+	openTestDB(t)
+	tournament := models.Tournament{Name: "Swiss", Status: models.StatusInProgress, Format: models.FormatSwiss, SwissRounds: 3, Rules: models.DefaultRuleSet()}
+	db.DB.Create(&tournament)
+	for _, nickname := range []string{"Aiger", "Multi", "Bird", "Zonamos"} {
+		p := createTestParticipant(t, nickname)
+		db.DB.Create(&models.TournamentParticipant{TournamentID: tournament.ID, ParticipantID: p.ID, Group: models.PhaseSwiss})
+	}
+	db.DB.Preload("TournamentParticipants").First(&tournament, tournament.ID)
+	if err := createMatches(db.DB, tournament, nextSwissRound(tournament.ID, tournament.TournamentParticipants, nil)); err != nil {
+		t.Fatalf("create round 1: %v", err)
+	}
+	db.DB.Model(&models.Match{}).Where("tournament_id = ?", tournament.ID).Update("winner_id", gorm.Expr("player1_id"))
+
+	countMatches := func() int64 {
+		var n int64
+		db.DB.Model(&models.Match{}).Where("tournament_id = ?", tournament.ID).Count(&n)
+		return n
+	}
+	path := fmt.Sprintf("/tournaments/%d/advance", tournament.ID)
+
+	// Writing the history entry fails, so the new round must not be kept either
+	if err := db.DB.Migrator().DropTable(&models.TournamentTransition{}); err != nil {
+		t.Fatalf("drop transitions: %v", err)
+	}
+	if w := serve(t, AdvanceTournamentPhase, http.MethodPost, "/tournaments/{id}/advance", path, nil); w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected the advance to fail, got %d", w.Code)
+	}
+	if n := countMatches(); n != 2 {
+		t.Errorf("expected only round 1 after the failed advance, got %d matches", n)
+	}
+
+	if err := db.Migrate(db.DB); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if w := serve(t, AdvanceTournamentPhase, http.MethodPost, "/tournaments/{id}/advance", path, nil); w.Code != http.StatusOK {
+		t.Fatalf("retry advance: %d %s", w.Code, w.Body.String())
+	}
+	if n := countMatches(); n != 4 {
+		t.Errorf("expected round 2 paired once, got %d matches", n)
+	}
+	var history []models.TournamentTransition
+	db.DB.Where("tournament_id = ?", tournament.ID).Find(&history)
+	if len(history) != 1 || history[0].Action != models.ActionAdvance {
+		t.Errorf("expected one advance in the history, got %+v", history)
+	}
+}
+
+func TestScoringFollowsLifecycle(t *testing.T) {
+	openTestDB(t)
+	m, p1, _ := createTestMatch(t)
+	path := fmt.Sprintf("/matches/%d", m.ID)
+	round := ScoreRequest{WinnerID: p1.ID, WinType: "Spin"}
+	if w := serve(t, UpdateMatchScore, http.MethodPost, "/matches/{id}/score", path+"/score", round); w.Code != http.StatusOK {
+		t.Fatalf("expected a group match scored while the groups are played, got %d %s", w.Code, w.Body.String())
+	}
+
+	// Once the bracket is seeded from the groups their results are locked
+	db.DB.Model(&models.Tournament{}).Where("id = ?", m.TournamentID).Update("status", models.StatusBracketInProgress)
+	locked := []struct {
+		name    string
+		handler http.HandlerFunc
+		suffix  string
+		body    interface{}
+	}{
+		{"score", UpdateMatchScore, "/score", round},
+		{"manual", ManualMatchScore, "/manual", ManualScoreRequest{ScoreP1: 4}},
+		{"reset", ResetMatch, "/reset", nil},
+		{"undo", UndoMatchRound, "/undo", nil},
+		{"time", CallTime, "/time", nil},
+		{"forfeit", ForfeitMatch, "/forfeit", ForfeitRequest{ParticipantID: p1.ID}},
+	}
+	for _, tt := range locked {
+		if w := serve(t, tt.handler, http.MethodPost, "/matches/{id}"+tt.suffix, path+tt.suffix, tt.body); w.Code != http.StatusConflict {
+			t.Errorf("%s: expected 409 for a group match during the bracket, got %d", tt.name, w.Code)
+		}
+	}
+	db.DB.First(&m, m.ID)
+	if m.ScoreP1 != 1 {
+		t.Errorf("expected the group result untouched, got %d-%d", m.ScoreP1, m.ScoreP2)
+	}
+}
+
+func TestBracketMatchesWaitForTheBracket(t *testing.T) {
+	openTestDB(t)
+	_, final, _ := playedSemifinals(t)
+	db.DB.Model(&models.Tournament{}).Where("id = ?", final.TournamentID).Update("status", models.StatusInProgress)
+	finalPath := fmt.Sprintf("/matches/%d/reset", final.ID)
+	if w := serve(t, ResetMatch, http.MethodPost, "/matches/{id}/reset", finalPath, nil); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a bracket match before the bracket, got %d", w.Code)
+	}
+}
+
+func TestSignUpsAndRulesCloseWithTheDraw(t *testing.T) {
+	openTestDB(t)
+	tournament := models.Tournament{Name: "Cup", Status: models.StatusCreated, Rules: models.DefaultRuleSet()}
+	db.DB.Create(&tournament)
+	late := createTestParticipant(t, "Aiger")
+	join := fmt.Sprintf("/tournaments/%d/participants", tournament.ID)
+	rules := fmt.Sprintf("/tournaments/%d/rules", tournament.ID)
+	body := map[string]uint{"participant_id": late.ID}
+
+	if w := serve(t, UpdateTournamentRules, http.MethodPut, "/tournaments/{id}/rules", rules, map[string]int{"group_win_limit": 5}); w.Code != http.StatusOK {
+		t.Errorf("expected the rules editable before the draw, got %d %s", w.Code, w.Body.String())
+	}
+
+	db.DB.Model(&tournament).Update("status", models.StatusInProgress)
+	if w := serve(t, AddParticipantToTournament, http.MethodPost, "/tournaments/{id}/participants", join, body); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 adding a player once matches are played, got %d", w.Code)
+	}
+	var joined int64
+	db.DB.Model(&models.TournamentParticipant{}).Where("tournament_id = ?", tournament.ID).Count(&joined)
+	if joined != 0 {
+		t.Errorf("expected nobody added, got %d", joined)
+	}
+	if w := serve(t, UpdateTournamentRules, http.MethodPut, "/tournaments/{id}/rules", rules, map[string]int{"group_win_limit": 3}); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 changing the rules once matches are played, got %d", w.Code)
+	}
+}
+
+func TestResetTournament(t *testing.T) {
+	openTestDB(t)
+	m, p1, _ := createTestMatch(t)
+	path := fmt.Sprintf("/matches/%d/score", m.ID)
+	serve(t, UpdateMatchScore, http.MethodPost, "/matches/{id}/score", path, ScoreRequest{WinnerID: p1.ID, WinType: "Spin"})
+	db.DB.Model(&models.TournamentParticipant{}).Where("participant_id = ?", p1.ID).Update("withdrawn", true)
+
+	reset := fmt.Sprintf("/tournaments/%d/reset", m.TournamentID)
+	if w := serve(t, ResetTournament, http.MethodPost, "/tournaments/{id}/reset", reset, nil); w.Code != http.StatusOK {
+		t.Fatalf("reset: %d %s", w.Code, w.Body.String())
+	}
+
+	var tp models.TournamentParticipant
+	db.DB.Where("participant_id = ?", p1.ID).First(&tp)
+	if tp.Withdrawn || tp.Group != "" || tp.Wins != 0 {
+		t.Errorf("expected the player signed up afresh, got %+v", tp)
+	}
+	var matches, rounds int64
+	db.DB.Model(&models.Match{}).Where("tournament_id = ?", m.TournamentID).Count(&matches)
+	db.DB.Model(&models.MatchRound{}).Where("match_id = ?", m.ID).Count(&rounds)
+	if matches != 0 || rounds != 0 {
+		t.Errorf("expected matches and round logs deleted, got %d matches and %d rounds", matches, rounds)
+	}
+}
@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"bbx_tournament/models"
+	"math/bits"
+	"sort"
 )
 
 // generateMatchesFromGroups creates matches based on assigned groups using a Circle Method for rounds
@@ -61,30 +63,219 @@ func generateMatchesFromGroups(tournamentID uint, participants []models.Tourname
 	return matches
 }
 
-// generateBracketMatches creates a round of a single-elimination bracket
-func generateBracketMatches(tournamentID uint, playerIDs []uint, round int) []models.Match {
+// qualifier is a player entering the bracket with the group they came from
+type qualifier struct {
+	tp   models.TournamentParticipant
+	rank int // Finishing position in the group
+}
+
+// seedQualifiers takes the top perGroup players of every group and orders them into bracket seeds:
+// all group winners first, then all runners-up, and so on, each tier ordered by points then wins.
+// Places inside a group come from the tiebreaker chain stored in Rank.
+// Seeds are then swapped within their tier so group-mates land as far apart as possible.
+func seedQualifiers(participants []models.TournamentParticipant, perGroup int) []uint {
+	grouped := make(map[string][]models.TournamentParticipant)
+	for _, tp := range participants {
+		if tp.Group != "" && !tp.Withdrawn {
+			grouped[tp.Group] = append(grouped[tp.Group], tp)
+		}
+	}
+
+	var qualified []qualifier
+	for _, members := range grouped {
+		sort.Slice(members, func(i, j int) bool {
+			if members[i].Rank != members[j].Rank {
+				return members[i].Rank < members[j].Rank
+			}
+			if members[i].Points != members[j].Points {
+				return members[i].Points > members[j].Points
+			}
+			if members[i].Wins != members[j].Wins {
+				return members[i].Wins > members[j].Wins
+			}
+			return members[i].ParticipantID < members[j].ParticipantID
+		})
+
+		limit := perGroup
+		if len(members) < limit {
+			limit = len(members)
+		}
+		for i, tp := range members[:limit] {
+			qualified = append(qualified, qualifier{tp: tp, rank: i + 1})
+		}
+	}
+
+	sort.Slice(qualified, func(i, j int) bool {
+		a, b := qualified[i], qualified[j]
+		if a.rank != b.rank {
+			return a.rank < b.rank
+		}
+		if a.tp.Points != b.tp.Points {
+			return a.tp.Points > b.tp.Points
+		}
+		if a.tp.Wins != b.tp.Wins {
+			return a.tp.Wins > b.tp.Wins
+		}
+		if a.tp.Group != b.tp.Group {
+			return a.tp.Group < b.tp.Group
+		}
+		return a.tp.ParticipantID < b.tp.ParticipantID
+	})
+
+	separateGroupMates(qualified)
+
+	seeds := make([]uint, len(qualified))
+	for i, q := range qualified {
+		seeds[i] = q.tp.ParticipantID
+	}
+	return seeds
+}
+
+// separateGroupMates reorders seeds within the same group rank so that players from one group
+// meet as late as possible: never in round one, and on opposite halves where the draw allows.
+// Tiers are settled best first against the tiers above them, and only strictly better swaps are
+// taken in seed order, so the result is deterministic.
+func separateGroupMates(seeds []qualifier) {
+	if len(seeds) < 3 {
+		return
+	}
+
+	size := nextPowerOfTwo(len(seeds))
+	position := make([]int, size) // Bracket position of each seed index
+	for pos, seed := range bracketPlacement(size) {
+		position[seed-1] = pos
+	}
+	rounds := bits.Len(uint(size)) - 1
+
+	// cost counts group-mates among the first n seeds by the round they could first meet,
+	// better ranked pairs weighing more. Costs compare round by round, so one earlier
+	// meeting is worse than any number of later ones.
+	cost := func(n int) []int {
+		total := make([]int, rounds+1)
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				if seeds[i].tp.Group != seeds[j].tp.Group {
+					continue
+				}
+				meet := bits.Len(uint(position[i] ^ position[j]))
+				total[meet] += 2*len(seeds) - seeds[i].rank - seeds[j].rank
+			}
+		}
+		return total
+	}
+	less := func(a, b []int) bool {
+		for round := range a {
+			if a[round] != b[round] {
+				return a[round] < b[round]
+			}
+		}
+		return false
+	}
+
+	for start := 0; start < len(seeds); {
+		end := start
+		for end < len(seeds) && seeds[end].rank == seeds[start].rank {
+			end++
+		}
+
+		best := cost(end)
+		for improved := true; improved; {
+			improved = false
+			for i := start; i < end; i++ {
+				for j := i + 1; j < end; j++ {
+					if seeds[i].tp.Group == seeds[j].tp.Group {
+						continue
+					}
+					seeds[i], seeds[j] = seeds[j], seeds[i]
+					if c := cost(end); less(c, best) {
+						best = c
+						improved = true
+					} else {
+						seeds[i], seeds[j] = seeds[j], seeds[i]
+					}
+				}
+			}
+		}
+		start = end
+	}
+}
+
+// seedBracketMatches creates the first round of an elimination bracket.
+// seeds is ordered best first; the bracket is padded to the next power of two and
+// the missing seeds become byes for the top seeds, using standard 1-vs-N placement.
+func seedBracketMatches(tournamentID uint, seeds []uint, phase string) []models.Match {
 	var matches []models.Match
-	n := len(playerIDs)
+	n := len(seeds)
 	if n < 2 {
 		return matches
 	}
 
-	// Simple pairing: 0 vs 1, 2 vs 3, etc.
-	for i := 0; i < n; i += 2 {
-		if i+1 >= n {
-			// Odd number of players? Should ideally be handled by Byes earlier,
-			// but for now let's just break or handle if needed.
-			// Bracket should usually be power of 2 from selection logic.
-			break
+	placement := bracketPlacement(nextPowerOfTwo(n))
+	for i := 0; i < len(placement); i += 2 {
+		s1, s2 := placement[i], placement[i+1]
+		m := models.Match{
+			TournamentID: tournamentID,
+			Player1ID:    seeds[s1-1],
+			Phase:        phase,
+			Round:        1,
+			Slot:         i / 2,
+		}
+		if s2 <= n {
+			m.Player2ID = seeds[s2-1]
+		} else {
+			// Seed s2 does not exist, s1 moves on
+			winner := m.Player1ID
+			m.WinnerID = &winner
+			m.IsBye = true
+		}
+		matches = append(matches, m)
+	}
+
+	return matches
+}
+
+// bracketPlacement returns the seed at each bracket position so that 1 meets N, 2 meets N-1,
+// and the top two seeds can only meet in the final. size must be a power of two.
+func bracketPlacement(size int) []int {
+	placement := []int{1}
+	for len(placement) < size {
+		next := make([]int, 0, len(placement)*2)
+		total := len(placement)*2 + 1
+		for _, seed := range placement {
+			next = append(next, seed, total-seed)
 		}
-		p1 := playerIDs[i]
-		p2 := playerIDs[i+1]
+		placement = next
+	}
+	return placement
+}
+
+// nextPowerOfTwo returns the smallest power of two >= n
+func nextPowerOfTwo(n int) int {
+	size := 1
+	for size < n {
+		size *= 2
+	}
+	return size
+}
+
+// generateBracketMatches creates the next round of an elimination bracket.
+// playerIDs are the previous round's winners in slot order, so neighbours meet.
+func generateBracketMatches(tournamentID uint, playerIDs []uint, round int, phase string) []models.Match {
+	var matches []models.Match
+	n := len(playerIDs)
+	if n < 2 {
+		return matches
+	}
+
+	// Pairing: 0 vs 1, 2 vs 3, etc. Rounds after the first always hold a power of two
+	for i := 0; i+1 < n; i += 2 {
 		matches = append(matches, models.Match{
 			TournamentID: tournamentID,
-			Player1ID:    p1,
-			Player2ID:    p2,
-			Phase:        "Bracket",
+			Player1ID:    playerIDs[i],
+			Player2ID:    playerIDs[i+1],
+			Phase:        phase,
 			Round:        round,
+			Slot:         i / 2,
 			ScoreP1:      0,
 			ScoreP2:      0,
 		})
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"bbx_tournament/models"
+	"reflect"
+	"testing"
+)
+
+func TestBracketPlacement(t *testing.T) {
+	tests := []struct {
+		size     int
+		expected []int
+	}{
+		{2, []int{1, 2}},
+		{4, []int{1, 4, 2, 3}},
+		{8, []int{1, 8, 4, 5, 2, 7, 3, 6}},
+	}
+
+	for _, tt := range tests {
+		if got := bracketPlacement(tt.size); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("size %d: expected %v, got %v", tt.size, tt.expected, got)
+		}
+	}
+}
+
+func TestSeedBracketMatchesByes(t *testing.T) {
+	tests := []struct {
+		players, matches, byes int
+	}{
+		{2, 1, 0},
+		{3, 2, 1},
+		{5, 4, 3},
+		{6, 4, 2},
+		{8, 4, 0},
+		{12, 8, 4},
+	}
+
+	for _, tt := range tests {
+		seeds := make([]uint, tt.players)
+		for i := range seeds {
+			seeds[i] = uint(i + 1) // Participant ID equals seed
+		}
+
+		matches := seedBracketMatches(1, seeds, models.PhaseBracket)
+		if len(matches) != tt.matches {
+			t.Fatalf("%d players: expected %d matches, got %d", tt.players, tt.matches, len(matches))
+		}
+
+		byes := 0
+		seen := make(map[uint]bool)
+		for i, m := range matches {
+			if m.Slot != i || m.Round != 1 {
+				t.Errorf("%d players: match %d has slot %d round %d", tt.players, i, m.Slot, m.Round)
+			}
+			seen[m.Player1ID] = true
+			if m.IsBye {
+				byes++
+				if m.WinnerID == nil || *m.WinnerID != m.Player1ID {
+					t.Errorf("%d players: bye for seed %d should be decided", tt.players, m.Player1ID)
+				}
+				// Byes always go to the best seeds
+				if int(m.Player1ID) > tt.byes {
+					t.Errorf("%d players: seed %d should not get a bye", tt.players, m.Player1ID)
+				}
+				continue
+			}
+			seen[m.Player2ID] = true
+		}
+		if byes != tt.byes {
+			t.Errorf("%d players: expected %d byes, got %d", tt.players, tt.byes, byes)
+		}
+		if len(seen) != tt.players {
+			t.Errorf("%d players: expected every player placed once, got %d", tt.players, len(seen))
+		}
+	}
+}
+
+func TestSeedQualifiers(t *testing.T) {
+	participants := []models.TournamentParticipant{
+		{ParticipantID: 1, Group: "A", Points: 9},
+		{ParticipantID: 2, Group: "A", Points: 3},
+		{ParticipantID: 3, Group: "A", Points: 6},
+		{ParticipantID: 4, Group: "B", Points: 12},
+		{ParticipantID: 5, Group: "B", Points: 0},
+		{ParticipantID: 6, Group: "B", Points: 6},
+	}
+
+	// Group winners first (B1 has more points than A1), then runners-up swapped so
+	// each winner opens against the other group's runner-up
+	expected := []uint{4, 1, 6, 3}
+	if got := seedQualifiers(participants, 2); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestSeedQualifiersSeparatesGroupMates(t *testing.T) {
+	tests := []struct {
+		groups, perGroup int
+	}{
+		{2, 4},
+		{4, 2},
+		{4, 4},
+		{3, 4},
+		{8, 2},
+	}
+
+	for _, tt := range tests {
+		var participants []models.TournamentParticipant
+		groupOf := make(map[uint]string)
+		id := uint(1)
+		for g := 0; g < tt.groups; g++ {
+			label := string(rune('A' + g))
+			for rank := 0; rank < tt.perGroup; rank++ {
+				// Uneven points so every tier is ordered differently per group
+				participants = append(participants, models.TournamentParticipant{
+					ParticipantID: id, Group: label, Points: (tt.perGroup-rank)*6 + (g*5)%7,
+				})
+				groupOf[id] = label
+				id++
+			}
+		}
+
+		seeds := seedQualifiers(participants, tt.perGroup)
+		if again := seedQualifiers(participants, tt.perGroup); !reflect.DeepEqual(seeds, again) {
+			t.Fatalf("%dx%d: seeding is not deterministic", tt.groups, tt.perGroup)
+		}
+
+		for _, m := range seedBracketMatches(1, seeds, models.PhaseBracket) {
+			if !m.IsBye && groupOf[m.Player1ID] == groupOf[m.Player2ID] {
+				t.Errorf("%dx%d: group-mates %d and %d meet in round one", tt.groups, tt.perGroup, m.Player1ID, m.Player2ID)
+			}
+		}
+
+		// The top two of every group must sit on opposite halves
+		size := nextPowerOfTwo(len(seeds))
+		half := make(map[uint]int)
+		for pos, seed := range bracketPlacement(size) {
+			if seed <= len(seeds) {
+				half[seeds[seed-1]] = pos * 2 / size
+			}
+		}
+		for g := 0; g < tt.groups; g++ {
+			first, second := uint(g*tt.perGroup+1), uint(g*tt.perGroup+2)
+			if half[first] == half[second] {
+				t.Errorf("%dx%d: group %s top two share a half", tt.groups, tt.perGroup, groupOf[first])
+			}
+		}
+	}
+}
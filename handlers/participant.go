@@ -5,6 +5,8 @@ import (
 	"bbx_tournament/models"
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -45,6 +47,63 @@ func CreateParticipant(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(participant)
 }
 
+// ParticipantUpdateRequest holds the profile fields to change, fields left out are kept
+type ParticipantUpdateRequest struct {
+	Nickname *string `json:"nickname"`
+	Avatar   *string `json:"avatar"`
+	Club     *string `json:"club"`
+}
+
+// UpdateParticipant edits a participant's profile, e.g. to fix the club used to keep club-mates apart
+func UpdateParticipant(w http.ResponseWriter, r *http.Request) {
+	participantID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var req ParticipantUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var participant models.Participant
+	if result := db.DB.First(&participant, participantID); result.Error != nil {
+		http.Error(w, "Participant not found", http.StatusNotFound)
+		return
+	}
+
+	if req.Nickname != nil {
+		nickname := strings.TrimSpace(*req.Nickname)
+		if nickname == "" {
+			http.Error(w, "Nickname cannot be empty", http.StatusBadRequest)
+			return
+		}
+		var taken int64
+		db.DB.Model(&models.Participant{}).Where("nickname = ? AND id <> ?", nickname, participant.ID).Count(&taken)
+		if taken > 0 {
+			http.Error(w, "Nickname already taken", http.StatusConflict)
+			return
+		}
+		participant.Nickname = nickname
+	}
+	if req.Avatar != nil {
+		participant.Avatar = *req.Avatar
+	}
+	if req.Club != nil {
+		participant.Club = strings.TrimSpace(*req.Club)
+	}
+
+	if err := db.DB.Omit("Decks").Save(&participant).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(participant)
+}
+
 // ArchiveParticipant soft-deletes a participant
 func ArchiveParticipant(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"bbx_tournament/models"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestUpdateParticipant(t *testing.T) {
+	openTestDB(t)
+	p := createTestParticipant(t, "Aiger")
+	createTestParticipant(t, "Multi")
+	path := fmt.Sprintf("/participants/%d", p.ID)
+
+	club := " Hobby Shop "
+	w := serve(t, UpdateParticipant, http.MethodPut, "/participants/{id}", path, ParticipantUpdateRequest{Club: &club})
+	if w.Code != http.StatusOK {
+		t.Fatalf("update participant: %d %s", w.Code, w.Body.String())
+	}
+	var got models.Participant
+	decode(t, w, &got)
+	if got.Club != "Hobby Shop" || got.Nickname != "Aiger" {
+		t.Errorf("expected only the club changed, got %+v", got)
+	}
+
+	taken := "Multi"
+	if w := serve(t, UpdateParticipant, http.MethodPut, "/participants/{id}", path, ParticipantUpdateRequest{Nickname: &taken}); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a taken nickname, got %d", w.Code)
+	}
+	empty := " "
+	if w := serve(t, UpdateParticipant, http.MethodPut, "/participants/{id}", path, ParticipantUpdateRequest{Nickname: &empty}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an empty nickname, got %d", w.Code)
+	}
+	if w := serve(t, UpdateParticipant, http.MethodPut, "/participants/{id}", "/participants/99", ParticipantUpdateRequest{Club: &club}); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown participant, got %d", w.Code)
+	}
+}
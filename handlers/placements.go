@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"bbx_tournament/models"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// thirdPlaceMatch pairs the semifinal losers when the tournament plays for third. It returns
+// nil when a semifinal was a bye, as there is nobody to play.
+func thirdPlaceMatch(t models.Tournament, semifinals []models.Match, round int) []models.Match {
+	if !t.ThirdPlaceMatch || len(semifinals) != 2 {
+		return nil
+	}
+	var losers []uint
+	for _, m := range semifinals {
+		if m.IsBye || m.WinnerID == nil {
+			return nil
+		}
+		losers = append(losers, loserOf(m))
+	}
+	return []models.Match{{
+		TournamentID: t.ID,
+		Player1ID:    losers[0],
+		Player2ID:    losers[1],
+		Phase:        models.PhaseThirdPlace,
+		Round:        round,
+	}}
+}
+
+// finalPlacements ranks every participant of a finished tournament. Bracket players are placed
+// by the round they went out in, sharing the place with everyone else leaving in that round.
+// Everyone else follows by their place in the group or Swiss pool, sharing it across groups.
+func finalPlacements(t models.Tournament, matches []models.Match) map[uint]int {
+	var tiers [][]uint
+	if t.BracketFormat == models.BracketDoubleElimination {
+		tiers = doubleEliminationExits(matches)
+	} else {
+		tiers = singleEliminationExits(matches)
+	}
+
+	placed := make(map[uint]bool)
+	for _, tier := range tiers {
+		for _, pid := range tier {
+			placed[pid] = true
+		}
+	}
+
+	// Bracket players without a recorded exit, then the rest by group place
+	var unplaced []uint
+	byRank := make(map[int][]uint)
+	for _, tp := range t.TournamentParticipants {
+		switch {
+		case placed[tp.ParticipantID]:
+		case tp.Seed > 0:
+			unplaced = append(unplaced, tp.ParticipantID)
+		default:
+			byRank[tp.Rank] = append(byRank[tp.Rank], tp.ParticipantID)
+		}
+	}
+	if len(unplaced) > 0 {
+		tiers = append(tiers, unplaced)
+	}
+	ranks := make([]int, 0, len(byRank))
+	for rank := range byRank {
+		ranks = append(ranks, rank)
+	}
+	// Unranked players (rank 0) go last
+	sort.Slice(ranks, func(i, j int) bool {
+		if (ranks[i] == 0) != (ranks[j] == 0) {
+			return ranks[j] == 0
+		}
+		return ranks[i] < ranks[j]
+	})
+	for _, rank := range ranks {
+		tiers = append(tiers, byRank[rank])
+	}
+
+	placements := make(map[uint]int)
+	next := 1
+	for _, tier := range tiers {
+		for _, pid := range tier {
+			placements[pid] = next
+		}
+		next += len(tier)
+	}
+	return placements
+}
+
+// singleEliminationExits lists bracket players best first, grouped by the round they lost in
+func singleEliminationExits(matches []models.Match) [][]uint {
+	byRound := make(map[int][]models.Match)
+	maxRound := 0
+	var third *models.Match
+	for i, m := range matches {
+		switch m.Phase {
+		case models.PhaseBracket:
+			byRound[m.Round] = append(byRound[m.Round], m)
+			if m.Round > maxRound {
+				maxRound = m.Round
+			}
+		case models.PhaseThirdPlace:
+			third = &matches[i]
+		}
+	}
+	if maxRound == 0 {
+		return nil
+	}
+
+	var tiers [][]uint
+	final := byRound[maxRound]
+	if len(final) == 1 && final[0].WinnerID != nil {
+		tiers = append(tiers, []uint{*final[0].WinnerID})
+		if !final[0].IsBye {
+			tiers = append(tiers, []uint{loserOf(final[0])})
+		}
+	}
+
+	playedForThird := make(map[uint]bool)
+	if third != nil && third.WinnerID != nil {
+		tiers = append(tiers, []uint{*third.WinnerID}, []uint{loserOf(*third)})
+		playedForThird[third.Player1ID] = true
+		playedForThird[third.Player2ID] = true
+	}
+
+	for round := maxRound - 1; round >= 1; round-- {
+		var tier []uint
+		for _, m := range byRound[round] {
+			if m.IsBye || m.WinnerID == nil {
+				continue
+			}
+			if loser := loserOf(m); !playedForThird[loser] {
+				tier = append(tier, loser)
+			}
+		}
+		if len(tier) > 0 {
+			tiers = append(tiers, tier)
+		}
+	}
+	return tiers
+}
+
+// doubleEliminationExits lists bracket players best first: the grand final decides first and
+// second, everyone else is grouped by the losers bracket round of their second loss
+func doubleEliminationExits(matches []models.Match) [][]uint {
+	var finals []models.Match
+	byRound := make(map[int][]models.Match)
+	maxRound := 0
+	for _, m := range matches {
+		switch m.Phase {
+		case models.PhaseGrandFinal:
+			finals = append(finals, m)
+		case models.PhaseLosersBracket:
+			byRound[m.Round] = append(byRound[m.Round], m)
+			if m.Round > maxRound {
+				maxRound = m.Round
+			}
+		}
+	}
+
+	var tiers [][]uint
+	top := make(map[uint]bool)
+	if len(finals) > 0 {
+		sortBracketMatches(finals)
+		last := finals[len(finals)-1]
+		if last.WinnerID != nil {
+			tiers = append(tiers, []uint{*last.WinnerID}, []uint{loserOf(last)})
+			top[last.Player1ID] = true
+			top[last.Player2ID] = true
+		}
+	}
+
+	for round := maxRound; round >= 1; round-- {
+		var tier []uint
+		for _, m := range byRound[round] {
+			if m.IsBye || m.WinnerID == nil {
+				continue
+			}
+			if loser := loserOf(m); !top[loser] {
+				tier = append(tier, loser)
+			}
+		}
+		if len(tier) > 0 {
+			tiers = append(tiers, tier)
+		}
+	}
+	return tiers
+}
+
+// storePlacements writes the final placements of a finished tournament
+func storePlacements(tx *gorm.DB, t models.Tournament, matches []models.Match) error {
+	for pid, placement := range finalPlacements(t, matches) {
+		if err := tx.Model(&models.TournamentParticipant{}).
+			Where("tournament_id = ? AND participant_id = ?", t.ID, pid).
+			Update("placement", placement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"bbx_tournament/models"
+	"reflect"
+	"testing"
+)
+
+// playSingleElimination runs a single-elimination bracket where the lower ID always wins
+func playSingleElimination(t models.Tournament, seeds []uint) []models.Match {
+	lowerWins := func(m *models.Match) {
+		if m.WinnerID != nil {
+			return
+		}
+		winner := m.Player1ID
+		if m.Player2ID < winner {
+			winner = m.Player2ID
+		}
+		m.WinnerID = &winner
+	}
+
+	current := seedBracketMatches(t.ID, seeds, models.PhaseBracket)
+	var matches []models.Match
+	for round := 1; ; round++ {
+		var winners []uint
+		var bracket []models.Match
+		for i := range current {
+			lowerWins(&current[i])
+			if current[i].Phase == models.PhaseBracket {
+				winners = append(winners, *current[i].WinnerID)
+				bracket = append(bracket, current[i])
+			}
+		}
+		matches = append(matches, current...)
+		if len(winners) < 2 {
+			return matches
+		}
+		current = generateBracketMatches(t.ID, winners, round+1, models.PhaseBracket)
+		if len(winners) == 2 {
+			current = append(current, thirdPlaceMatch(t, bracket, round+1)...)
+		}
+	}
+}
+
+func TestFinalPlacementsSingleElimination(t *testing.T) {
+	var participants []models.TournamentParticipant
+	var seeds []uint
+	for pid := uint(1); pid <= 12; pid++ {
+		tp := models.TournamentParticipant{ParticipantID: pid, Group: "A", Rank: int(pid)}
+		if pid <= 6 {
+			tp.Seed = int(pid)
+			seeds = append(seeds, pid)
+		}
+		participants = append(participants, tp)
+	}
+
+	for _, third := range []bool{false, true} {
+		tournament := models.Tournament{ThirdPlaceMatch: third, TournamentParticipants: participants}
+		placements := finalPlacements(tournament, playSingleElimination(tournament, seeds))
+
+		got := make([]int, 12)
+		for pid, place := range placements {
+			got[pid-1] = place
+		}
+		// 6 players: seeds 1 and 2 have byes, 3-6 and 4-5 play the first round
+		expected := []int{1, 2, 3, 3, 5, 5, 7, 8, 9, 10, 11, 12}
+		if third {
+			expected = []int{1, 2, 3, 4, 5, 5, 7, 8, 9, 10, 11, 12}
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("third place match %v: expected %v, got %v", third, expected, got)
+		}
+	}
+}
+
+func TestFinalPlacementsDoubleElimination(t *testing.T) {
+	const players = 8
+	matches := simulateDoubleElimination(t, players, false, func(m models.Match) uint {
+		if m.Player2ID == 0 || m.Player1ID < m.Player2ID {
+			return m.Player1ID
+		}
+		return m.Player2ID
+	})
+
+	var participants []models.TournamentParticipant
+	for pid := uint(1); pid <= players; pid++ {
+		participants = append(participants, models.TournamentParticipant{ParticipantID: pid, Seed: int(pid)})
+	}
+	tournament := models.Tournament{BracketFormat: models.BracketDoubleElimination, TournamentParticipants: participants}
+	placements := finalPlacements(tournament, matches)
+
+	// Better players always win: 1 and 2 meet in the grand final, then the losers bracket
+	// knocks out 3, 4, 5-6 and 7-8
+	expected := map[uint]int{1: 1, 2: 2, 3: 3, 4: 4, 5: 5, 6: 5, 7: 7, 8: 7}
+	if !reflect.DeepEqual(placements, expected) {
+		t.Errorf("expected %v, got %v", expected, placements)
+	}
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"bbx_tournament/db"
+	"bbx_tournament/models"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// Rollback scopes
+const (
+	RollbackRound   = "Round"   // Undo the latest advance
+	RollbackBracket = "Bracket" // Drop the whole bracket and go back to the group or Swiss stage
+)
+
+// RollbackRequest picks how far to roll back; an empty body rolls back one round
+type RollbackRequest struct {
+	Scope string `json:"scope"` // Round (default), Bracket
+}
+
+// RollbackTournament undoes bracket progress without touching group or Swiss results. Rolling back
+// a round deletes the matches generated by the latest advance; rolling back the first round, or
+// the whole bracket, returns the tournament to InProgress so qualification can be corrected.
+// A finished tournament is reopened with its last round in place.
+func RollbackTournament(w http.ResponseWriter, r *http.Request) {
+	tourIDStr := chi.URLParam(r, "id")
+	tourID, _ := strconv.Atoi(tourIDStr)
+
+	var req RollbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Scope == "" {
+		req.Scope = RollbackRound
+	}
+	if req.Scope != RollbackRound && req.Scope != RollbackBracket {
+		http.Error(w, "Scope must be Round or Bracket", http.StatusBadRequest)
+		return
+	}
+
+	var t models.Tournament
+	if result := db.DB.Preload("Matches").Preload("TournamentParticipants").First(&t, tourID); result.Error != nil {
+		http.Error(w, "Tournament not found", http.StatusNotFound)
+		return
+	}
+	if !checkTransition(w, t, models.ActionRollback) {
+		return
+	}
+	from := t.Status
+
+	rounds := bracketRounds(t, t.Matches)
+
+	var remove []models.Match
+	switch {
+	case len(rounds) == 0:
+		// Finished straight from the groups, nothing was seeded
+		t.Status = models.StatusInProgress
+	case req.Scope == RollbackRound && t.Status == models.StatusFinished:
+		t.Status = models.StatusBracketInProgress
+	case req.Scope == RollbackRound && len(rounds) > 1:
+		remove = rounds[len(rounds)-1]
+	default:
+		for _, round := range rounds {
+			remove = append(remove, round...)
+		}
+		t.Status = models.StatusInProgress
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		for _, m := range remove {
+			if err := tx.Where("match_id = ?", m.ID).Delete(&models.MatchRound{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&models.Match{}, m.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		reset := map[string]interface{}{"placement": 0}
+		if t.Status == models.StatusInProgress {
+			reset["seed"] = 0
+		}
+		if err := tx.Model(&models.TournamentParticipant{}).Where("tournament_id = ?", t.ID).Updates(reset).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&t).Update("status", t.Status).Error; err != nil {
+			return err
+		}
+		if err := recordTransition(tx, t, from, models.ActionRollback); err != nil {
+			return err
+		}
+		return rebuildStandings(tx, t.ID)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	liveEvents.publish(t.ID)
+
+	db.DB.Preload("Matches.Player1").Preload("Matches.Player2").Preload("TournamentParticipants.Participant").First(&t, tourID)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(t)
+}
+
+// bracketRounds splits the bracket matches into the batches each advance created, oldest first.
+// Double elimination can open a winners and a losers round in one advance, so its batches are
+// found by replaying the bracket from the first round.
+func bracketRounds(t models.Tournament, matches []models.Match) [][]models.Match {
+	type key struct {
+		phase string
+		round int
+	}
+	byKey := make(map[key][]models.Match)
+	maxRound := 0
+	for _, m := range matches {
+		if !models.IsBracketPhase(m.Phase) {
+			continue
+		}
+		byKey[key{m.Phase, m.Round}] = append(byKey[key{m.Phase, m.Round}], m)
+		if m.Round > maxRound {
+			maxRound = m.Round
+		}
+	}
+
+	var rounds [][]models.Match
+	if t.BracketFormat != models.BracketDoubleElimination {
+		// The match for third is created alongside the final
+		for round := 1; round <= maxRound; round++ {
+			batch := append(byKey[key{models.PhaseBracket, round}], byKey[key{models.PhaseThirdPlace, round}]...)
+			if len(batch) > 0 {
+				rounds = append(rounds, batch)
+			}
+		}
+		return rounds
+	}
+
+	history := byKey[key{models.PhaseWinnersBracket, 1}]
+	if len(history) == 0 {
+		return nil
+	}
+	rounds = append(rounds, history)
+	for {
+		next, finished := nextDoubleEliminationMatches(t, history)
+		if finished {
+			return rounds
+		}
+		seen := make(map[key]bool)
+		var batch []models.Match
+		for _, m := range next {
+			k := key{m.Phase, m.Round}
+			if !seen[k] {
+				seen[k] = true
+				batch = append(batch, byKey[k]...)
+			}
+		}
+		if len(batch) == 0 {
+			return rounds
+		}
+		rounds = append(rounds, batch)
+		history = append(append([]models.Match{}, history...), batch...)
+	}
+}
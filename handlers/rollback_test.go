@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"bbx_tournament/db"
+	"bbx_tournament/models"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestBracketRoundsSingleElimination(t *testing.T) {
+	tournament := models.Tournament{ThirdPlaceMatch: true}
+	matches := playSingleElimination(tournament, []uint{1, 2, 3, 4, 5, 6})
+
+	rounds := bracketRounds(tournament, matches)
+	if len(rounds) != 3 {
+		t.Fatalf("expected 3 rounds, got %d", len(rounds))
+	}
+	// The final and the match for third are rolled back together
+	last := rounds[2]
+	if len(last) != 2 || last[0].Phase != models.PhaseBracket || last[1].Phase != models.PhaseThirdPlace {
+		t.Errorf("expected the final and the match for third last, got %+v", last)
+	}
+}
+
+func TestBracketRoundsDoubleElimination(t *testing.T) {
+	for players := 2; players <= 12; players++ {
+		matches := simulateDoubleElimination(t, players, true, func(m models.Match) uint { return m.Player2ID })
+		tournament := models.Tournament{BracketFormat: models.BracketDoubleElimination, BracketReset: true}
+
+		// simulateDoubleElimination appends each advance in turn, starting from the seeded round
+		var advances [][]models.Match
+		played := nextPowerOfTwo(players) / 2
+		advances = append(advances, matches[:played])
+		for played < len(matches) {
+			next, _ := nextDoubleEliminationMatches(tournament, matches[:played])
+			advances = append(advances, matches[played:played+len(next)])
+			played += len(next)
+		}
+
+		rounds := bracketRounds(tournament, matches)
+		if len(rounds) != len(advances) {
+			t.Fatalf("%d players: expected %d rounds, got %d", players, len(advances), len(rounds))
+		}
+		for i := range rounds {
+			if len(rounds[i]) != len(advances[i]) {
+				t.Errorf("%d players: round %d has %d matches, expected %d", players, i+1, len(rounds[i]), len(advances[i]))
+			}
+		}
+	}
+}
+
+func TestRollbackTournament(t *testing.T) {
+	openTestDB(t)
+	semifinals, final, third := playedSemifinals(t)
+	tournamentID := final.TournamentID
+	winner := semifinals[0].Player1ID
+	group := models.Match{TournamentID: tournamentID, Phase: "A", Round: 1, Player1ID: semifinals[0].Player1ID, Player2ID: semifinals[1].Player1ID, WinnerID: &winner, ScoreP1: 4}
+	db.DB.Create(&group)
+
+	path := fmt.Sprintf("/tournaments/%d/rollback", tournamentID)
+	rollback := func(scope string) models.Tournament {
+		t.Helper()
+		w := serve(t, RollbackTournament, http.MethodPost, "/tournaments/{id}/rollback", path, RollbackRequest{Scope: scope})
+		if w.Code != http.StatusOK {
+			t.Fatalf("rollback %s: %d %s", scope, w.Code, w.Body.String())
+		}
+		var got models.Tournament
+		decode(t, w, &got)
+		return got
+	}
+	exists := func(m models.Match) bool {
+		var n int64
+		db.DB.Model(&models.Match{}).Where("id = ?", m.ID).Count(&n)
+		return n > 0
+	}
+
+	// The final and the match for third go, the semifinals stay
+	if got := rollback(RollbackRound); got.Status != models.StatusBracketInProgress {
+		t.Errorf("expected the bracket still in progress, got %s", got.Status)
+	}
+	if exists(final) || exists(third) || !exists(semifinals[0]) || !exists(semifinals[1]) {
+		t.Error("expected only the latest round deleted")
+	}
+	var rounds int64
+	db.DB.Model(&models.MatchRound{}).Where("match_id = ?", final.ID).Count(&rounds)
+	if rounds != 0 {
+		t.Errorf("expected the final's round log deleted, got %d rounds", rounds)
+	}
+
+	// Dropping the bracket goes back to the groups with their results
+	if got := rollback(RollbackBracket); got.Status != models.StatusInProgress {
+		t.Errorf("expected the groups in progress again, got %s", got.Status)
+	}
+	if exists(semifinals[0]) || exists(semifinals[1]) {
+		t.Error("expected the whole bracket deleted")
+	}
+	var kept models.Match
+	if err := db.DB.First(&kept, group.ID).Error; err != nil || kept.WinnerID == nil || *kept.WinnerID != winner || kept.ScoreP1 != 4 {
+		t.Errorf("expected the group result kept, got %+v (%v)", kept, err)
+	}
+
+	var history []models.TournamentTransition
+	db.DB.Where("tournament_id = ?", tournamentID).Order("id ASC").Find(&history)
+	if len(history) != 2 || history[0].Action != models.ActionRollback || history[0].To != models.StatusBracketInProgress ||
+		history[1].From != models.StatusBracketInProgress || history[1].To != models.StatusInProgress {
+		t.Errorf("expected both rollbacks in the history, got %+v", history)
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"bbx_tournament/db"
+	"bbx_tournament/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// UpdateTournamentRules changes the rule set, only allowed until the matches are generated
+func UpdateTournamentRules(w http.ResponseWriter, r *http.Request) {
+	tourIDStr := chi.URLParam(r, "id")
+	tourID, _ := strconv.Atoi(tourIDStr)
+
+	var t models.Tournament
+	if result := db.DB.Preload("Matches").Preload("TournamentParticipants").First(&t, tourID); result.Error != nil {
+		http.Error(w, "Tournament not found", http.StatusNotFound)
+		return
+	}
+	if !checkTransition(w, t, models.ActionUpdateRules) {
+		return
+	}
+
+	// Decode over the current rules so partial payloads keep the other values
+	if err := json.NewDecoder(r.Body).Decode(&t.Rules); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateRuleSet(t.Rules); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := db.DB.Save(&t).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
+
+// validateRuleSet rejects rule sets a match could never be decided under
+func validateRuleSet(rules models.RuleSet) error {
+	if rules.GroupWinLimit <= 0 || rules.BracketWinLimit <= 0 {
+		return errors.New("Win limits must be positive")
+	}
+	if rules.SpinPoints < 0 || rules.OverPoints < 0 || rules.BurstPoints < 0 || rules.OutPoints < 0 || rules.XtremePoints < 0 {
+		return errors.New("Finish points cannot be negative")
+	}
+	if rules.SpinPoints+rules.OverPoints+rules.BurstPoints+rules.OutPoints+rules.XtremePoints == 0 {
+		return errors.New("At least one finish type must score points")
+	}
+	if rules.MatchWinPoints < 0 || rules.DrawPoints < 0 {
+		return errors.New("Standings points cannot be negative")
+	}
+	if rules.GroupRoundLimit < 0 {
+		return errors.New("Round limit cannot be negative")
+	}
+	if rules.PenaltyWarnings < 0 || rules.PenaltyPoints < 0 {
+		return errors.New("Penalty settings cannot be negative")
+	}
+	seen := make(map[string]bool)
+	for _, name := range rules.Tiebreakers {
+		switch name {
+		case models.TiebreakHeadToHead, models.TiebreakFinishDifferential, models.TiebreakXtremeFinishes, models.TiebreakWins, models.TiebreakPlayoff:
+		default:
+			return fmt.Errorf("Unknown tiebreaker %q", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("Tiebreaker %s is listed twice", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// tournamentRules loads the rule set a tournament plays by
+func tournamentRules(tx *gorm.DB, tournamentID uint) (models.RuleSet, error) {
+	var t models.Tournament
+	if err := tx.First(&t, tournamentID).Error; err != nil {
+		return models.RuleSet{}, err
+	}
+	return t.Rules, nil
+}
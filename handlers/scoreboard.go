@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"bbx_tournament/db"
+	"bbx_tournament/models"
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+)
+
+// scoreboardPing is how often an idle scoreboard socket is pinged. The match is read again
+// then too, so bracket corrections made through another match still show up.
+const scoreboardPing = 10 * time.Second
+
+// scoreboards is keyed by match
+var scoreboards = newEventHub()
+
+var scoreboardUpgrader = websocket.Upgrader{
+	// Overlays are loaded from OBS and other origins, same as the open CORS policy
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// ScoreboardPlayer is one side of a scoreboard
+type ScoreboardPlayer struct {
+	ParticipantID uint   `json:"participant_id"` // 0 while waiting for an earlier bracket match
+	Nickname      string `json:"nickname"`
+	Avatar        string `json:"avatar"`
+	Score         int    `json:"score"`
+}
+
+// Scoreboard is what a stream overlay shows for a match
+type Scoreboard struct {
+	MatchID      uint                `json:"match_id"`
+	TournamentID uint                `json:"tournament_id"`
+	Phase        string              `json:"phase"`
+	Round        int                 `json:"round"`
+	Players      [2]ScoreboardPlayer `json:"players"`
+	PointsToWin  int                 `json:"points_to_win"`
+	LastFinish   string              `json:"last_finish"`    // Spin, Over, Burst, Out, Xtreme or Draw of the latest round, "" before the first
+	LastWinnerID uint                `json:"last_winner_id"` // Who took the latest round, 0 for a draw
+	WinnerID     *uint               `json:"winner_id"`
+	IsDraw       bool                `json:"is_draw"`
+	Forfeit      string              `json:"forfeit,omitempty"`
+	Decided      bool                `json:"decided"`
+}
+
+// loadScoreboard reads the current scoreboard of a match
+func loadScoreboard(matchID uint) (Scoreboard, error) {
+	var m models.Match
+	if err := db.DB.Preload("Player1").Preload("Player2").Preload("Rounds", orderedRounds).First(&m, matchID).Error; err != nil {
+		return Scoreboard{}, err
+	}
+	rules, err := tournamentRules(db.DB, m.TournamentID)
+	if err != nil {
+		return Scoreboard{}, err
+	}
+	return buildScoreboard(m, rules), nil
+}
+
+// buildScoreboard lays out a match loaded with its players and ordered rounds
+func buildScoreboard(m models.Match, rules models.RuleSet) Scoreboard {
+	board := Scoreboard{
+		MatchID:      m.ID,
+		TournamentID: m.TournamentID,
+		Phase:        m.Phase,
+		Round:        m.Round,
+		PointsToWin:  rules.WinLimit(m.Phase),
+		WinnerID:     m.WinnerID,
+		IsDraw:       m.IsDraw,
+		Forfeit:      m.Forfeit,
+		Decided:      m.Decided(),
+	}
+	board.Players[0] = ScoreboardPlayer{ParticipantID: m.Player1ID, Score: m.ScoreP1}
+	if m.Player1ID != 0 {
+		board.Players[0].Nickname, board.Players[0].Avatar = m.Player1.Nickname, m.Player1.Avatar
+	}
+	board.Players[1] = ScoreboardPlayer{ParticipantID: m.Player2ID, Score: m.ScoreP2}
+	if m.Player2ID != 0 {
+		board.Players[1].Nickname, board.Players[1].Avatar = m.Player2.Nickname, m.Player2.Avatar
+	}
+
+	// Penalties are not finishes
+	for i := len(m.Rounds) - 1; i >= 0; i-- {
+		if m.Rounds[i].WinType != models.WinTypePenalty {
+			board.LastFinish, board.LastWinnerID = m.Rounds[i].WinType, m.Rounds[i].WinnerID
+			break
+		}
+	}
+	return board
+}
+
+// GetMatchScoreboard returns the scoreboard of a match
+func GetMatchScoreboard(w http.ResponseWriter, r *http.Request) {
+	matchID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	board, err := loadScoreboard(uint(matchID))
+	if err != nil {
+		http.Error(w, "Match not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(board)
+}
+
+// MatchScoreboardSocket pushes the scoreboard of a match over a WebSocket: once on connect,
+// then whenever it changes. Anything the client sends is ignored.
+func MatchScoreboardSocket(w http.ResponseWriter, r *http.Request) {
+	matchID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	// Subscribe before the first read so no change is lost in between
+	wake := scoreboards.subscribe(uint(matchID))
+	defer scoreboards.unsubscribe(uint(matchID), wake)
+
+	board, err := loadScoreboard(uint(matchID))
+	if err != nil {
+		http.Error(w, "Match not found", http.StatusNotFound)
+		return
+	}
+
+	conn, err := scoreboardUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // The upgrader already answered
+	}
+	defer conn.Close()
+
+	// Reading is needed to notice the client going away and to answer its pings
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	var sent []byte
+	send := func(board Scoreboard) error {
+		data, err := json.Marshal(board)
+		if err != nil || bytes.Equal(data, sent) {
+			return err
+		}
+		sent = data
+		return conn.WriteMessage(websocket.TextMessage, data)
+	}
+
+	if err := send(board); err != nil {
+		return
+	}
+	ping := time.NewTicker(scoreboardPing)
+	defer ping.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-wake:
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(scoreboardPing)); err != nil {
+				return
+			}
+		}
+		board, err := loadScoreboard(uint(matchID))
+		if err != nil {
+			// The match was removed, by a rollback or a corrected grand final
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "Match not found"), time.Now().Add(time.Second))
+			return
+		}
+		if err := send(board); err != nil {
+			return
+		}
+	}
+}
+
+// MatchOverlay serves a page showing the live scoreboard of a match, for use as an OBS browser
+// source. It is rendered with the current score and updated over the scoreboard socket.
+func MatchOverlay(w http.ResponseWriter, r *http.Request) {
+	matchID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	board, err := loadScoreboard(uint(matchID))
+	if err != nil {
+		http.Error(w, "Match not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := overlayTemplate.Execute(w, board); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+var overlayTemplate = template.Must(template.New("overlay").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Match {{.MatchID}}</title>
+<style>
+  body { margin: 0; background: transparent; font-family: "Segoe UI", Arial, sans-serif; color: #fff; }
+  .board { display: flex; align-items: center; width: 960px; background: rgba(16, 16, 28, 0.85); border-radius: 8px; overflow: hidden; }
+  .player { flex: 1; display: flex; align-items: center; gap: 12px; padding: 12px 16px; font-size: 28px; font-weight: 600; }
+  .player.right { flex-direction: row-reverse; text-align: right; }
+  .player img { width: 56px; height: 56px; border-radius: 50%; object-fit: cover; }
+  .player img[src=""] { display: none; }
+  .player.winner .name { color: #ffd54a; }
+  .score { font-size: 44px; font-weight: 700; min-width: 56px; text-align: center; }
+  .middle { display: flex; flex-direction: column; align-items: center; padding: 0 12px; font-size: 14px; text-transform: uppercase; opacity: 0.85; }
+  .finish { font-size: 18px; font-weight: 700; color: #ffd54a; min-height: 22px; }
+</style>
+</head>
+<body>
+{{$p1 := index .Players 0}}{{$p2 := index .Players 1}}
+<div class="board">
+  <div class="player" id="p1">
+    <img class="avatar" src="{{$p1.Avatar}}" alt="">
+    <span class="name">{{or $p1.Nickname "TBD"}}</span>
+    <span class="score">{{$p1.Score}}</span>
+  </div>
+  <div class="middle">
+    <span class="target">{{if .Decided}}{{or .Forfeit "Final"}}{{else}}First to {{.PointsToWin}}{{end}}</span>
+    <span class="finish">{{.LastFinish}}</span>
+  </div>
+  <div class="player right" id="p2">
+    <img class="avatar" src="{{$p2.Avatar}}" alt="">
+    <span class="name">{{or $p2.Nickname "TBD"}}</span>
+    <span class="score">{{$p2.Score}}</span>
+  </div>
+</div>
+<script>
+  var matchID = {{.MatchID}};
+
+  function render(board) {
+    ["p1", "p2"].forEach(function (id, i) {
+      var el = document.getElementById(id), player = board.players[i];
+      el.querySelector(".avatar").setAttribute("src", player.avatar || "");
+      el.querySelector(".name").textContent = player.nickname || "TBD";
+      el.querySelector(".score").textContent = player.score;
+      el.classList.toggle("winner", board.winner_id !== null && board.winner_id === player.participant_id);
+    });
+    document.querySelector(".target").textContent = board.decided ? (board.forfeit || "Final") : "First to " + board.points_to_win;
+    document.querySelector(".finish").textContent = board.last_finish;
+  }
+
+  // Reconnects when the server restarts or the network drops
+  function connect() {
+    var scheme = location.protocol === "https:" ? "wss://" : "ws://";
+    var socket = new WebSocket(scheme + location.host + "/matches/" + matchID + "/scoreboard/ws");
+    socket.onmessage = function (e) { render(JSON.parse(e.data)); };
+    socket.onclose = function () { setTimeout(connect, 2000); };
+  }
+  connect();
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"bbx_tournament/models"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestBuildScoreboard(t *testing.T) {
+	rules := models.DefaultRuleSet()
+	m := models.Match{
+		Phase:     models.PhaseBracket,
+		Player1ID: 1,
+		Player2ID: 2,
+		Player1:   models.Participant{Nickname: "Aiger", Avatar: "aiger.png"},
+		Player2:   models.Participant{Nickname: "Multi"},
+		ScoreP1:   3,
+		ScoreP2:   2,
+		Rounds: []models.MatchRound{
+			{Sequence: 1, WinnerID: 1, WinType: "Xtreme", Points: 3},
+			{Sequence: 2, WinnerID: 2, WinType: "Burst", Points: 2},
+			{Sequence: 3, WinnerID: 0, WinType: models.WinTypePenalty, Penalty: models.PenaltyLaunchError, OffenderID: 1},
+		},
+	}
+
+	board := buildScoreboard(m, rules)
+	if board.PointsToWin != rules.BracketWinLimit {
+		t.Errorf("expected %d points to win in the bracket, got %d", rules.BracketWinLimit, board.PointsToWin)
+	}
+	if board.Players[0].Nickname != "Aiger" || board.Players[0].Avatar != "aiger.png" || board.Players[0].Score != 3 {
+		t.Errorf("unexpected first player %+v", board.Players[0])
+	}
+	if board.Players[1].Nickname != "Multi" || board.Players[1].Score != 2 {
+		t.Errorf("unexpected second player %+v", board.Players[1])
+	}
+	// The warning is not a finish
+	if board.LastFinish != "Burst" || board.LastWinnerID != 2 {
+		t.Errorf("expected the last finish to be player 2's Burst, got %s by %d", board.LastFinish, board.LastWinnerID)
+	}
+
+	m.Phase = "A"
+	m.Rounds = nil
+	if board := buildScoreboard(m, rules); board.PointsToWin != rules.GroupWinLimit || board.LastFinish != "" {
+		t.Errorf("expected a group scoreboard with no finish yet, got %+v", board)
+	}
+}
+
+func TestBracketCorrectionWakesScoreboards(t *testing.T) {
+	openTestDB(t)
+	semifinals, final, third := playedSemifinals(t)
+	finalBoard := scoreboards.subscribe(final.ID)
+	defer scoreboards.unsubscribe(final.ID, finalBoard)
+	thirdBoard := scoreboards.subscribe(third.ID)
+	defer scoreboards.unsubscribe(third.ID, thirdBoard)
+
+	path := fmt.Sprintf("/matches/%d/reset", semifinals[0].ID)
+	if w := serve(t, ResetMatch, http.MethodPost, "/matches/{id}/reset", path, nil); w.Code != http.StatusOK {
+		t.Fatalf("reset: %d %s", w.Code, w.Body.String())
+	}
+	for name, wake := range map[string]chan struct{}{"final": finalBoard, "match for third": thirdBoard} {
+		select {
+		case <-wake:
+		default:
+			t.Errorf("expected the %s's scoreboard refreshed", name)
+		}
+	}
+}
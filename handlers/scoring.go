@@ -4,29 +4,28 @@ import (
 	"bbx_tournament/db"
 	"bbx_tournament/models"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
 )
 
 // ScoreRequest represents the payload for a score update
 type ScoreRequest struct {
-	WinnerID uint   `json:"winner_id"` // ID of the player who won the round
-	WinType  string `json:"win_type"`  // Spin, Over, Burst, Out, Xtreme
+	WinnerID uint   `json:"winner_id"` // ID of the player who won the round, 0 for a draw
+	WinType  string `json:"win_type"`  // Spin, Over, Burst, Out, Xtreme, Draw
+	// Optional combos launched this round, must come from each player's own decks
+	Player1BeybladeID *uint `json:"player1_beyblade_id"`
+	Player2BeybladeID *uint `json:"player2_beyblade_id"`
+	// Set instead of WinnerID and WinType to log a penalty against OffenderID
+	Penalty    string `json:"penalty"` // LaunchError, ReadySetViolation, Foul
+	OffenderID uint   `json:"offender_id"`
 	// Correction: "Out" and "Over" might be same/similar in some contexts but rules say:
 	// Over Finish (2), Out Finish (2), Burst (2), Spin (1), Xtreme (3)
 }
 
-// Points map based on rules
-var pointsMap = map[string]int{
-	"Spin":   1,
-	"Over":   2,
-	"Burst":  2,
-	"Out":    2,
-	"Xtreme": 3,
-}
-
 // UpdateMatchScore handles round updates
 func UpdateMatchScore(w http.ResponseWriter, r *http.Request) {
 	matchIDStr := chi.URLParam(r, "id")
@@ -38,62 +37,211 @@ func UpdateMatchScore(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	points, ok := pointsMap[req.WinType]
-	if !ok {
-		http.Error(w, "Invalid WinType", http.StatusBadRequest)
-		return
-	}
-
 	var m models.Match
 	// Preload to return full object if needed, or just update
-	if err := db.DB.Preload("Player1").Preload("Player2").First(&m, matchID).Error; err != nil {
+	if err := db.DB.Preload("Player1").Preload("Player2").Preload("Rounds", orderedRounds).First(&m, matchID).Error; err != nil {
 		http.Error(w, "Match not found", http.StatusNotFound)
 		return
 	}
 
-	if m.WinnerID != nil {
+	rules, ok := checkMatchScoring(w, m)
+	if !ok {
+		return
+	}
+
+	if m.Decided() {
 		http.Error(w, "Match already finished", http.StatusBadRequest)
 		return
 	}
+	if m.WaitingForPlayers() {
+		http.Error(w, "Match is waiting for an earlier bracket result", http.StatusBadRequest)
+		return
+	}
+
+	if !beybladeBelongsTo(req.Player1BeybladeID, m.Player1ID) || !beybladeBelongsTo(req.Player2BeybladeID, m.Player2ID) {
+		http.Error(w, "Beyblade does not belong to the player's decks", http.StatusBadRequest)
+		return
+	}
 
-	if req.WinnerID == m.Player1ID {
+	var points int
+	if req.Penalty != "" {
+		winnerID, penaltyPoints, err := assessPenalty(m, rules, req.Penalty, req.OffenderID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.WinnerID, req.WinType, points = winnerID, models.WinTypePenalty, penaltyPoints
+	} else {
+		var ok bool
+		if points, ok = rules.PointsFor(req.WinType); !ok {
+			http.Error(w, "Invalid WinType", http.StatusBadRequest)
+			return
+		}
+		if req.WinType == models.WinTypeDraw && req.WinnerID != 0 {
+			http.Error(w, "A draw round has no winner", http.StatusBadRequest)
+			return
+		}
+	}
+
+	switch {
+	case req.WinnerID == 0 && (req.WinType == models.WinTypeDraw || req.WinType == models.WinTypePenalty):
+		// A draw round or a warning, nobody scores
+	case req.WinnerID == m.Player1ID:
 		m.ScoreP1 += points
-	} else if req.WinnerID == m.Player2ID {
+	case req.WinnerID == m.Player2ID:
 		m.ScoreP2 += points
-	} else {
+	default:
 		http.Error(w, "Invalid WinnerID", http.StatusBadRequest)
 		return
 	}
 
-	// Check Win Condition (Group Stage: 7pts)
-	// TODO: Dynamic limit based on phase (Group=7, Bracket=10)
-	winLimit := 7
-	if m.Phase == "Bracket" {
-		winLimit = 10
+	round := models.MatchRound{
+		MatchID:           m.ID,
+		Sequence:          len(m.Rounds) + 1,
+		WinnerID:          req.WinnerID,
+		WinType:           req.WinType,
+		Points:            points,
+		Player1BeybladeID: req.Player1BeybladeID,
+		Player2BeybladeID: req.Player2BeybladeID,
+		Player1Combo:      beybladeCombo(req.Player1BeybladeID),
+		Player2Combo:      beybladeCombo(req.Player2BeybladeID),
 	}
-
-	if m.ScoreP1 >= winLimit {
-		pid := m.Player1ID
-		m.WinnerID = &pid
-	} else if m.ScoreP2 >= winLimit {
-		pid := m.Player2ID
-		m.WinnerID = &pid
+	if req.Penalty != "" {
+		round.Penalty, round.OffenderID = req.Penalty, req.OffenderID
+	}
+	if len(m.Rounds) > 0 {
+		round.Sequence = m.Rounds[len(m.Rounds)-1].Sequence + 1
 	}
 
-	if err := db.DB.Save(&m).Error; err != nil {
+	// The new round counts toward the round limit, penalties count toward the win limit only
+	decideMatch(&m, rules, playedRounds(append(m.Rounds, round)))
+
+	var corrected []uint
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&round).Error; err != nil {
+			return err
+		}
+		if err := tx.Omit("Rounds").Save(&m).Error; err != nil {
+			return err
+		}
+		scored := matchEvent(m)
+		scored.MatchRound = &round
+		if err := recordEvent(tx, m.TournamentID, models.EventRoundScored, scored); err != nil {
+			return err
+		}
+		if err := recordMatchDecided(tx, m); err != nil {
+			return err
+		}
+		touched, err := correctBracket(tx, m)
+		if err != nil {
+			return err
+		}
+		corrected = touched
+		return rebuildStandings(tx, m.TournamentID)
+	})
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	liveEvents.publish(m.TournamentID)
+	scoreboards.publish(m.ID)
+	for _, id := range corrected {
+		scoreboards.publish(id)
+	}
+	m.Rounds = append(m.Rounds, round)
 
-	// Update Stats if someone won
-	if m.WinnerID != nil {
-		updateWinnerStats(m.TournamentID, *m.WinnerID, req.WinType)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m)
+}
+
+// GetMatch returns a match with its ordered round log
+func GetMatch(w http.ResponseWriter, r *http.Request) {
+	matchID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var m models.Match
+	if err := db.DB.Preload("Player1").Preload("Player2").Preload("Rounds", orderedRounds).
+		Preload("Rounds.Player1Beyblade").Preload("Rounds.Player2Beyblade").First(&m, matchID).Error; err != nil {
+		http.Error(w, "Match not found", http.StatusNotFound)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(m)
 }
 
+// assessPenalty works out who a penalty scores for. Each kind of offence is counted per player
+// and match; the first rules.PenaltyWarnings are warnings worth nothing.
+func assessPenalty(m models.Match, rules models.RuleSet, penalty string, offenderID uint) (uint, int, error) {
+	switch penalty {
+	case models.PenaltyLaunchError, models.PenaltyReadySetViolation, models.PenaltyFoul:
+	default:
+		return 0, 0, fmt.Errorf("Invalid penalty %q", penalty)
+	}
+
+	var opponentID uint
+	switch offenderID {
+	case m.Player1ID:
+		opponentID = m.Player2ID
+	case m.Player2ID:
+		opponentID = m.Player1ID
+	default:
+		return 0, 0, fmt.Errorf("Invalid OffenderID")
+	}
+
+	previous := 0
+	for _, round := range m.Rounds {
+		if round.Penalty == penalty && round.OffenderID == offenderID {
+			previous++
+		}
+	}
+	if previous < rules.PenaltyWarnings || rules.PenaltyPoints == 0 {
+		return 0, 0, nil
+	}
+	return opponentID, rules.PenaltyPoints, nil
+}
+
+// playedRounds counts the rounds actually launched, leaving penalty entries out
+func playedRounds(rounds []models.MatchRound) int {
+	played := 0
+	for _, round := range rounds {
+		if round.WinType != models.WinTypePenalty {
+			played++
+		}
+	}
+	return played
+}
+
+// orderedRounds keeps preloaded round logs in play order
+func orderedRounds(tx *gorm.DB) *gorm.DB {
+	return tx.Order("sequence ASC")
+}
+
+// beybladeBelongsTo checks that an optional Beyblade comes from one of the participant's decks
+func beybladeBelongsTo(beybladeID *uint, participantID uint) bool {
+	if beybladeID == nil {
+		return true
+	}
+	var count int64
+	db.DB.Model(&models.Beyblade{}).
+		Joins("join decks on decks.id = beyblades.deck_id").
+		Where("beyblades.id = ? AND decks.participant_id = ?", *beybladeID, participantID).
+		Count(&count)
+	return count > 0
+}
+
+// beybladeCombo copies the parts of an optional Beyblade for the round log
+func beybladeCombo(beybladeID *uint) models.Combo {
+	var b models.Beyblade
+	if beybladeID == nil || db.DB.First(&b, *beybladeID).Error != nil {
+		return models.Combo{}
+	}
+	return models.Combo{Blade: b.Blade, Ratchet: b.Ratchet, Bit: b.Bit}
+}
+
 // ResetMatch resets the scores and winner of a match
 func ResetMatch(w http.ResponseWriter, r *http.Request) {
 	matchIDStr := chi.URLParam(r, "id")
@@ -105,19 +253,230 @@ func ResetMatch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if match.IsBye {
+		http.Error(w, "Bye matches cannot be scored", http.StatusBadRequest)
+		return
+	}
+	if _, ok := checkMatchScoring(w, match); !ok {
+		return
+	}
+
 	match.ScoreP1 = 0
 	match.ScoreP2 = 0
 	match.WinnerID = nil
-
-	if err := db.DB.Save(&match).Error; err != nil {
+	match.IsDraw = false
+	match.TimeCalled = false
+	match.Forfeit = ""
+
+	var corrected []uint
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		// The round log starts over with the match
+		if err := tx.Where("match_id = ?", match.ID).Delete(&models.MatchRound{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(&match).Error; err != nil {
+			return err
+		}
+		if err := recordMatchUpdated(tx, match); err != nil {
+			return err
+		}
+		touched, err := correctBracket(tx, match)
+		if err != nil {
+			return err
+		}
+		corrected = touched
+		return rebuildStandings(tx, match.TournamentID)
+	})
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	liveEvents.publish(match.TournamentID)
+	scoreboards.publish(match.ID)
+	for _, id := range corrected {
+		scoreboards.publish(id)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(match)
 }
 
+// UndoMatchRound removes the last recorded round and recomputes the match from the remaining log
+func UndoMatchRound(w http.ResponseWriter, r *http.Request) {
+	matchIDStr := chi.URLParam(r, "id")
+	matchID, _ := strconv.Atoi(matchIDStr)
+
+	var m models.Match
+	if err := db.DB.Preload("Player1").Preload("Player2").Preload("Rounds", orderedRounds).First(&m, matchID).Error; err != nil {
+		http.Error(w, "Match not found", http.StatusNotFound)
+		return
+	}
+
+	if m.IsBye {
+		http.Error(w, "Bye matches cannot be scored", http.StatusBadRequest)
+		return
+	}
+
+	if m.Forfeit != "" {
+		http.Error(w, "Reset the match to clear a forfeit", http.StatusBadRequest)
+		return
+	}
+
+	if len(m.Rounds) == 0 {
+		http.Error(w, "No rounds to undo", http.StatusBadRequest)
+		return
+	}
+
+	rules, ok := checkMatchScoring(w, m)
+	if !ok {
+		return
+	}
+
+	// Scores are rebuilt from the log, which would silently drop a manual override
+	if p1, p2 := logScores(m, m.Rounds); p1 != m.ScoreP1 || p2 != m.ScoreP2 {
+		http.Error(w, "Score was set manually and no longer matches the round log, reset the match or set the score again", http.StatusConflict)
+		return
+	}
+
+	last := m.Rounds[len(m.Rounds)-1]
+	m.Rounds = m.Rounds[:len(m.Rounds)-1]
+	m.ScoreP1, m.ScoreP2 = logScores(m, m.Rounds)
+	// Undoing reopens a match ended at the time limit, time can be called again after
+	m.TimeCalled = false
+	decideMatch(&m, rules, playedRounds(m.Rounds))
+
+	var corrected []uint
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&last).Error; err != nil {
+			return err
+		}
+		if err := tx.Omit("Rounds").Save(&m).Error; err != nil {
+			return err
+		}
+		if err := recordMatchUpdated(tx, m); err != nil {
+			return err
+		}
+		touched, err := correctBracket(tx, m)
+		if err != nil {
+			return err
+		}
+		corrected = touched
+		return rebuildStandings(tx, m.TournamentID)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	liveEvents.publish(m.TournamentID)
+	scoreboards.publish(m.ID)
+	for _, id := range corrected {
+		scoreboards.publish(id)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m)
+}
+
+// logScores totals the points each player took in the rounds
+func logScores(m models.Match, rounds []models.MatchRound) (int, int) {
+	var p1, p2 int
+	for _, round := range rounds {
+		if round.WinnerID == m.Player1ID {
+			p1 += round.Points
+		} else if round.WinnerID == m.Player2ID {
+			p2 += round.Points
+		}
+	}
+	return p1, p2
+}
+
+// decideMatch sets or clears the result from the current scores. Outside the bracket a match
+// also ends once rounds reaches the round limit or time was called: the leader wins, a level
+// score is a draw.
+func decideMatch(m *models.Match, rules models.RuleSet, rounds int) {
+	m.IsDraw = false
+	winLimit := rules.WinLimit(m.Phase)
+	if m.ScoreP1 >= winLimit {
+		pid := m.Player1ID
+		m.WinnerID = &pid
+	} else if m.ScoreP2 >= winLimit {
+		pid := m.Player2ID
+		m.WinnerID = &pid
+	} else {
+		m.WinnerID = nil // Clear winner if score drops below limit
+	}
+
+	if m.WinnerID != nil || models.IsBracketPhase(m.Phase) {
+		return
+	}
+	limitReached := rules.GroupRoundLimit > 0 && rounds >= rules.GroupRoundLimit
+	if !limitReached && !m.TimeCalled {
+		return
+	}
+	switch {
+	case m.ScoreP1 > m.ScoreP2:
+		pid := m.Player1ID
+		m.WinnerID = &pid
+	case m.ScoreP2 > m.ScoreP1:
+		pid := m.Player2ID
+		m.WinnerID = &pid
+	default:
+		m.IsDraw = true
+	}
+}
+
+// CallTime ends a group or Swiss match at the time limit on its current score
+func CallTime(w http.ResponseWriter, r *http.Request) {
+	matchIDStr := chi.URLParam(r, "id")
+	matchID, _ := strconv.Atoi(matchIDStr)
+
+	var m models.Match
+	if err := db.DB.Preload("Player1").Preload("Player2").Preload("Rounds", orderedRounds).First(&m, matchID).Error; err != nil {
+		http.Error(w, "Match not found", http.StatusNotFound)
+		return
+	}
+
+	if m.IsBye {
+		http.Error(w, "Bye matches cannot be scored", http.StatusBadRequest)
+		return
+	}
+	if models.IsBracketPhase(m.Phase) {
+		http.Error(w, "Bracket matches are played to the win limit", http.StatusBadRequest)
+		return
+	}
+	if m.Decided() {
+		http.Error(w, "Match already finished", http.StatusBadRequest)
+		return
+	}
+
+	rules, ok := checkMatchScoring(w, m)
+	if !ok {
+		return
+	}
+
+	m.TimeCalled = true
+	decideMatch(&m, rules, playedRounds(m.Rounds))
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Rounds").Save(&m).Error; err != nil {
+			return err
+		}
+		if err := recordMatchDecided(tx, m); err != nil {
+			return err
+		}
+		return rebuildStandings(tx, m.TournamentID)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	liveEvents.publish(m.TournamentID)
+	scoreboards.publish(m.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m)
+}
+
 // ManualScoreRequest
 type ManualScoreRequest struct {
 	ScoreP1 int `json:"score_p1"`
@@ -136,56 +495,58 @@ func ManualMatchScore(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var match models.Match
-	if result := db.DB.Preload("Player1").Preload("Player2").First(&match, matchID); result.Error != nil {
+	if result := db.DB.Preload("Player1").Preload("Player2").Preload("Rounds", orderedRounds).First(&match, matchID); result.Error != nil {
 		http.Error(w, "Match not found", http.StatusNotFound)
 		return
 	}
 
-	match.ScoreP1 = req.ScoreP1
-	match.ScoreP2 = req.ScoreP2
-
-	// Check for Winner override or clear
-	winLimit := 7
-	if match.Phase == "Bracket" {
-		winLimit = 10
+	if match.IsBye {
+		http.Error(w, "Bye matches cannot be scored", http.StatusBadRequest)
+		return
 	}
-
-	if match.ScoreP1 >= winLimit {
-		match.WinnerID = &match.Player1ID
-	} else if match.ScoreP2 >= winLimit {
-		match.WinnerID = &match.Player2ID
-	} else {
-		match.WinnerID = nil // Clear winner if score drops below limit
+	if match.WaitingForPlayers() {
+		http.Error(w, "Match is waiting for an earlier bracket result", http.StatusBadRequest)
+		return
 	}
 
-	if err := db.DB.Save(&match).Error; err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	rules, ok := checkMatchScoring(w, match)
+	if !ok {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(match)
-}
-
-func updateWinnerStats(tournamentID, participantID uint, winType string) {
-	var tp models.TournamentParticipant
-	if err := db.DB.Where("tournament_id = ? AND participant_id = ?", tournamentID, participantID).First(&tp).Error; err != nil {
+	match.ScoreP1 = req.ScoreP1
+	match.ScoreP2 = req.ScoreP2
+	match.Forfeit = ""
+
+	// Check for Winner override or clear. The round log is left alone but still counts toward
+	// the round limit, so a corrected score keeps a match that ran out of rounds finished.
+	decideMatch(&match, rules, playedRounds(match.Rounds))
+
+	var corrected []uint
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Rounds").Save(&match).Error; err != nil {
+			return err
+		}
+		if err := recordMatchDecided(tx, match); err != nil {
+			return err
+		}
+		touched, err := correctBracket(tx, match)
+		if err != nil {
+			return err
+		}
+		corrected = touched
+		return rebuildStandings(tx, match.TournamentID)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	tp.Wins++
-
-	switch winType {
-	case "Spin":
-		tp.SpinFinishes++
-	case "Burst":
-		tp.BurstFinishes++
-	case "Over", "Out":
-		tp.OverFinishes++
-	case "Xtreme":
-		tp.XtremeFinishes++
+	liveEvents.publish(match.TournamentID)
+	scoreboards.publish(match.ID)
+	for _, id := range corrected {
+		scoreboards.publish(id)
 	}
 
-	tp.Points += 3
-	db.DB.Save(&tp)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(match)
 }
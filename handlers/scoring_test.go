@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"bbx_tournament/db"
+	"bbx_tournament/models"
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"testing"
 )
@@ -27,13 +30,69 @@ func TestUpdateMatchScore(t *testing.T) {
 		{"Xtreme", "Xtreme", 3},
 	}
 
+	rules := models.DefaultRuleSet()
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if pointsMap[tt.winType] != tt.expected {
-				t.Errorf("expected %d points for %s, got %d", tt.expected, tt.winType, pointsMap[tt.winType])
+			points, ok := rules.PointsFor(tt.winType)
+			if !ok || points != tt.expected {
+				t.Errorf("expected %d points for %s, got %d", tt.expected, tt.winType, points)
 			}
 		})
 	}
+
+	if points, ok := rules.PointsFor(models.WinTypeDraw); !ok || points != 0 {
+		t.Errorf("expected a draw round to be valid and worth nothing, got %d", points)
+	}
+
+	if _, ok := rules.PointsFor("Ring Out"); ok {
+		t.Errorf("expected unknown win type to be rejected")
+	}
+}
+
+func TestDecideMatchUsesRuleSet(t *testing.T) {
+	rules := models.DefaultRuleSet()
+	rules.GroupWinLimit = 4
+	rules.BracketWinLimit = 5
+
+	m := models.Match{Player1ID: 1, Player2ID: 2, Phase: "Group A", ScoreP1: 4}
+	decideMatch(&m, rules, 1)
+	if m.WinnerID == nil || *m.WinnerID != 1 {
+		t.Fatalf("expected player 1 to win a 4-point group match, got %v", m.WinnerID)
+	}
+
+	m = models.Match{Player1ID: 1, Player2ID: 2, Phase: "Bracket", ScoreP1: 4}
+	decideMatch(&m, rules, 1)
+	if m.WinnerID != nil {
+		t.Errorf("expected bracket match to need 5 points, got winner %v", *m.WinnerID)
+	}
+}
+
+func TestDecideMatchAtRoundLimit(t *testing.T) {
+	rules := models.DefaultRuleSet()
+	rules.GroupRoundLimit = 5
+
+	m := models.Match{Player1ID: 1, Player2ID: 2, Phase: "Group A", ScoreP1: 3, ScoreP2: 3}
+	decideMatch(&m, rules, 4)
+	if m.Decided() {
+		t.Fatalf("expected the match to go on before the limit")
+	}
+	decideMatch(&m, rules, 5)
+	if !m.IsDraw || m.WinnerID != nil {
+		t.Errorf("expected a draw at the round limit, got winner %v draw %v", m.WinnerID, m.IsDraw)
+	}
+
+	m = models.Match{Player1ID: 1, Player2ID: 2, Phase: "Group A", ScoreP1: 2, ScoreP2: 4, TimeCalled: true}
+	decideMatch(&m, rules, 2)
+	if m.IsDraw || m.WinnerID == nil || *m.WinnerID != 2 {
+		t.Errorf("expected the leader to win when time is called, got winner %v", m.WinnerID)
+	}
+
+	// The bracket plays on to the win limit
+	m = models.Match{Player1ID: 1, Player2ID: 2, Phase: models.PhaseBracket, ScoreP1: 3, ScoreP2: 3, TimeCalled: true}
+	decideMatch(&m, rules, 9)
+	if m.Decided() {
+		t.Errorf("expected a bracket match not to end on a limit")
+	}
 }
 
 func TestManualScoreLogic(t *testing.T) {
@@ -50,3 +109,293 @@ func TestManualScoreLogic(t *testing.T) {
 		t.Errorf("decoding failed, got %v", data)
 	}
 }
+
+func TestAssessPenalty(t *testing.T) {
+	rules := models.DefaultRuleSet()
+	m := models.Match{Player1ID: 1, Player2ID: 2}
+
+	// First launch error is a warning
+	winner, points, err := assessPenalty(m, rules, models.PenaltyLaunchError, 1)
+	if err != nil || winner != 0 || points != 0 {
+		t.Fatalf("expected a warning, got winner %d points %d err %v", winner, points, err)
+	}
+	m.Rounds = append(m.Rounds, models.MatchRound{WinType: models.WinTypePenalty, Penalty: models.PenaltyLaunchError, OffenderID: 1})
+
+	// A foul is counted on its own
+	if winner, _, _ := assessPenalty(m, rules, models.PenaltyFoul, 1); winner != 0 {
+		t.Errorf("expected a first foul to be a warning, got winner %d", winner)
+	}
+	// The second launch error scores for the opponent
+	winner, points, _ = assessPenalty(m, rules, models.PenaltyLaunchError, 1)
+	if winner != 2 || points != rules.PenaltyPoints {
+		t.Errorf("expected %d points for player 2, got %d for %d", rules.PenaltyPoints, points, winner)
+	}
+	// Player 2 has no warning yet
+	if winner, _, _ := assessPenalty(m, rules, models.PenaltyLaunchError, 2); winner != 0 {
+		t.Errorf("expected player 2's first launch error to be a warning, got winner %d", winner)
+	}
+
+	if _, _, err := assessPenalty(m, rules, "Sneezing", 1); err == nil {
+		t.Errorf("expected an unknown penalty to be rejected")
+	}
+	if _, _, err := assessPenalty(m, rules, models.PenaltyFoul, 3); err == nil {
+		t.Errorf("expected an offender outside the match to be rejected")
+	}
+
+	if played := playedRounds(append(m.Rounds, models.MatchRound{WinType: "Spin"})); played != 1 {
+		t.Errorf("expected penalties not to count as played rounds, got %d", played)
+	}
+}
+
+// createTestMatch sets up a group match between two new players of a tournament in progress
+func createTestMatch(t *testing.T) (models.Match, models.Participant, models.Participant) {
+	t.Helper()
+	p1 := createTestParticipant(t, "Aiger")
+	p2 := createTestParticipant(t, "Multi")
+	tournament := models.Tournament{Name: "Cup", Status: models.StatusInProgress, Rules: models.DefaultRuleSet()}
+	if err := db.DB.Create(&tournament).Error; err != nil {
+		t.Fatalf("create tournament: %v", err)
+	}
+	for _, p := range []models.Participant{p1, p2} {
+		db.DB.Create(&models.TournamentParticipant{TournamentID: tournament.ID, ParticipantID: p.ID, Group: "A"})
+	}
+	m := models.Match{TournamentID: tournament.ID, Player1ID: p1.ID, Player2ID: p2.ID, Phase: "A", Round: 1}
+	if err := db.DB.Create(&m).Error; err != nil {
+		t.Fatalf("create match: %v", err)
+	}
+	return m, p1, p2
+}
+
+func TestBeybladeBelongsTo(t *testing.T) {
+	openTestDB(t)
+	owner := createTestParticipant(t, "Aiger")
+	other := createTestParticipant(t, "Multi")
+	deck := createTestDeck(t, owner, standardDeck("Attack"))
+	id := deck.Beyblades[0].ID
+
+	if !beybladeBelongsTo(nil, owner.ID) {
+		t.Error("expected no Beyblade to be accepted")
+	}
+	if !beybladeBelongsTo(&id, owner.ID) {
+		t.Error("expected the owner's Beyblade to be accepted")
+	}
+	if beybladeBelongsTo(&id, other.ID) {
+		t.Error("expected another player's Beyblade to be refused")
+	}
+	unknown := id + 100
+	if beybladeBelongsTo(&unknown, owner.ID) {
+		t.Error("expected an unknown Beyblade to be refused")
+	}
+}
+
+func TestRoundLogKeepsCombosAfterDeckEdit(t *testing.T) {
+	openTestDB(t)
+	m, p1, p2 := createTestMatch(t)
+	deck1 := createTestDeck(t, p1, standardDeck("Attack"))
+	deck2 := createTestDeck(t, p2, standardDeck("Defense"))
+	matchPath := fmt.Sprintf("/matches/%d", m.ID)
+
+	req := ScoreRequest{WinnerID: p1.ID, WinType: "Burst", Player1BeybladeID: &deck1.Beyblades[0].ID, Player2BeybladeID: &deck2.Beyblades[2].ID}
+	if w := serve(t, UpdateMatchScore, http.MethodPost, "/matches/{id}/score", matchPath+"/score", req); w.Code != http.StatusOK {
+		t.Fatalf("score round: %d %s", w.Code, w.Body.String())
+	}
+	stolen := ScoreRequest{WinnerID: p1.ID, WinType: "Spin", Player1BeybladeID: &deck2.Beyblades[0].ID}
+	if w := serve(t, UpdateMatchScore, http.MethodPost, "/matches/{id}/score", matchPath+"/score", stolen); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for the opponent's Beyblade, got %d", w.Code)
+	}
+
+	// Player 1 swaps the Blade they launched
+	edit := standardDeck("Attack")
+	edit.Beyblades[0].Blade = "Phoenix Wing"
+	deckPath := fmt.Sprintf("/participants/%d/decks/%d", p1.ID, deck1.ID)
+	if w := serve(t, UpdateDeck, http.MethodPut, deckPattern, deckPath, edit); w.Code != http.StatusOK {
+		t.Fatalf("update deck: %d %s", w.Code, w.Body.String())
+	}
+
+	var got models.Match
+	decode(t, serve(t, GetMatch, http.MethodGet, "/matches/{id}", matchPath, nil), &got)
+	if len(got.Rounds) != 1 {
+		t.Fatalf("expected 1 logged round, got %d", len(got.Rounds))
+	}
+	round := got.Rounds[0]
+	if round.Sequence != 1 || round.WinnerID != p1.ID || round.WinType != "Burst" || round.Points != 2 {
+		t.Errorf("unexpected round %+v", round)
+	}
+	want1 := models.Combo{Blade: "Dran Sword", Ratchet: "3-60", Bit: "Flat"}
+	want2 := models.Combo{Blade: "Wizard Arrow", Ratchet: "4-80", Bit: "Ball"}
+	if round.Player1Combo != want1 || round.Player2Combo != want2 {
+		t.Errorf("expected the combos as launched, got %+v and %+v", round.Player1Combo, round.Player2Combo)
+	}
+}
+
+func TestMigrateBackfillsRoundCombos(t *testing.T) {
+	openTestDB(t)
+	m, p1, _ := createTestMatch(t)
+	deck := createTestDeck(t, p1, standardDeck("Attack"))
+
+	// A round logged before combos were copied, whose Beyblade was replaced since
+	round := models.MatchRound{MatchID: m.ID, Sequence: 1, WinnerID: p1.ID, WinType: "Spin", Points: 1, Player1BeybladeID: &deck.Beyblades[1].ID}
+	db.DB.Create(&round)
+	db.DB.Delete(&models.Beyblade{}, deck.Beyblades[1].ID)
+
+	if err := db.Migrate(db.DB); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	db.DB.First(&round, round.ID)
+	if want := (models.Combo{Blade: "Hells Scythe", Ratchet: "4-60", Bit: "Taper"}); round.Player1Combo != want {
+		t.Errorf("expected the combo backfilled from the deleted Beyblade, got %+v", round.Player1Combo)
+	}
+	if round.Player2Combo != (models.Combo{}) {
+		t.Errorf("expected no combo for a side without a Beyblade, got %+v", round.Player2Combo)
+	}
+}
+
+func TestUndoMatchRound(t *testing.T) {
+	openTestDB(t)
+	m, p1, p2 := createTestMatch(t)
+	path := fmt.Sprintf("/matches/%d", m.ID)
+	score := func(winner uint, winType string) {
+		t.Helper()
+		req := ScoreRequest{WinnerID: winner, WinType: winType}
+		if w := serve(t, UpdateMatchScore, http.MethodPost, "/matches/{id}/score", path+"/score", req); w.Code != http.StatusOK {
+			t.Fatalf("score round: %d %s", w.Code, w.Body.String())
+		}
+	}
+	undo := func() *models.Match {
+		t.Helper()
+		w := serve(t, UndoMatchRound, http.MethodPost, "/matches/{id}/undo", path+"/undo", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("undo: %d %s", w.Code, w.Body.String())
+		}
+		var got models.Match
+		decode(t, w, &got)
+		return &got
+	}
+
+	score(p1.ID, "Xtreme")
+	score(p2.ID, "Burst")
+	score(p1.ID, "Xtreme")
+	score(p1.ID, "Spin") // 7-2, decided
+	got := undo()
+	if got.ScoreP1 != 6 || got.ScoreP2 != 2 || got.WinnerID != nil || len(got.Rounds) != 3 {
+		t.Errorf("expected 6-2 undecided with 3 rounds left, got %d-%d winner %v, %d rounds", got.ScoreP1, got.ScoreP2, got.WinnerID, len(got.Rounds))
+	}
+	var tp models.TournamentParticipant
+	db.DB.Where("participant_id = ?", p1.ID).First(&tp)
+	if tp.Wins != 0 || tp.Points != 0 {
+		t.Errorf("expected the undone win taken out of the standings, got %d wins and %d points", tp.Wins, tp.Points)
+	}
+
+	// A manual score does not match the log, undoing would throw it away
+	manual := ManualScoreRequest{ScoreP1: 5, ScoreP2: 5}
+	if w := serve(t, ManualMatchScore, http.MethodPost, "/matches/{id}/manual", path+"/manual", manual); w.Code != http.StatusOK {
+		t.Fatalf("manual score: %d %s", w.Code, w.Body.String())
+	}
+	if w := serve(t, UndoMatchRound, http.MethodPost, "/matches/{id}/undo", path+"/undo", nil); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 undoing over a manual score, got %d", w.Code)
+	}
+	db.DB.First(&m, m.ID)
+	if m.ScoreP1 != 5 || m.ScoreP2 != 5 {
+		t.Errorf("expected the manual score kept, got %d-%d", m.ScoreP1, m.ScoreP2)
+	}
+
+	// Setting the score back to the log total allows undoing again
+	manual = ManualScoreRequest{ScoreP1: 6, ScoreP2: 2}
+	serve(t, ManualMatchScore, http.MethodPost, "/matches/{id}/manual", path+"/manual", manual)
+	if got := undo(); got.ScoreP1 != 3 || got.ScoreP2 != 2 {
+		t.Errorf("expected 3-2 after the second undo, got %d-%d", got.ScoreP1, got.ScoreP2)
+	}
+}
+
+func TestUndoAfterTimeCalled(t *testing.T) {
+	openTestDB(t)
+	m, p1, p2 := createTestMatch(t)
+	path := fmt.Sprintf("/matches/%d", m.ID)
+	serve(t, UpdateMatchScore, http.MethodPost, "/matches/{id}/score", path+"/score", ScoreRequest{WinnerID: p1.ID, WinType: "Xtreme"})
+	serve(t, UpdateMatchScore, http.MethodPost, "/matches/{id}/score", path+"/score", ScoreRequest{WinnerID: p2.ID, WinType: "Spin"})
+	if w := serve(t, CallTime, http.MethodPost, "/matches/{id}/time", path+"/time", nil); w.Code != http.StatusOK {
+		t.Fatalf("call time: %d %s", w.Code, w.Body.String())
+	}
+
+	w := serve(t, UndoMatchRound, http.MethodPost, "/matches/{id}/undo", path+"/undo", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("undo: %d %s", w.Code, w.Body.String())
+	}
+	var got models.Match
+	decode(t, w, &got)
+	if got.Decided() || got.TimeCalled || got.ScoreP1 != 3 || got.ScoreP2 != 0 {
+		t.Errorf("expected the match reopened at 3-0, got %d-%d winner %v time called %v", got.ScoreP1, got.ScoreP2, got.WinnerID, got.TimeCalled)
+	}
+}
+
+func TestManualScoreAtRoundLimit(t *testing.T) {
+	openTestDB(t)
+	m, p1, p2 := createTestMatch(t)
+	db.DB.Model(&models.Tournament{}).Where("id = ?", m.TournamentID).Update("rule_group_round_limit", 2)
+	path := fmt.Sprintf("/matches/%d", m.ID)
+
+	for _, winner := range []uint{p1.ID, p2.ID} {
+		req := ScoreRequest{WinnerID: winner, WinType: "Spin"}
+		if w := serve(t, UpdateMatchScore, http.MethodPost, "/matches/{id}/score", path+"/score", req); w.Code != http.StatusOK {
+			t.Fatalf("score round: %d %s", w.Code, w.Body.String())
+		}
+	}
+
+	// Corrected to another level score after the round limit: still a draw
+	w := serve(t, ManualMatchScore, http.MethodPost, "/matches/{id}/manual", path+"/manual", ManualScoreRequest{ScoreP1: 3, ScoreP2: 3})
+	var got models.Match
+	decode(t, w, &got)
+	if !got.IsDraw || got.WinnerID != nil {
+		t.Errorf("expected the corrected match drawn at the round limit, got draw %v winner %v", got.IsDraw, got.WinnerID)
+	}
+
+	// Corrected below the win limit with a leader: the leader takes it, the match stays over
+	w = serve(t, ManualMatchScore, http.MethodPost, "/matches/{id}/manual", path+"/manual", ManualScoreRequest{ScoreP1: 2, ScoreP2: 4})
+	decode(t, w, &got)
+	if got.WinnerID == nil || *got.WinnerID != p2.ID {
+		t.Errorf("expected player 2 to win on the corrected score, got winner %v draw %v", got.WinnerID, got.IsDraw)
+	}
+	var rounds int64
+	db.DB.Model(&models.MatchRound{}).Where("match_id = ?", m.ID).Count(&rounds)
+	if rounds != 2 {
+		t.Errorf("expected the round log left alone, got %d rounds", rounds)
+	}
+}
+
+func TestPenaltyOnMigratedTournament(t *testing.T) {
+	openTestDB(t)
+	m, p1, p2 := createTestMatch(t)
+
+	// The tournament predates penalties: the columns are added by the migration
+	for _, column := range []string{"rule_penalty_warnings", "rule_penalty_points"} {
+		if err := db.DB.Migrator().DropColumn(&models.Tournament{}, column); err != nil {
+			t.Fatalf("drop %s: %v", column, err)
+		}
+	}
+	if err := db.Migrate(db.DB); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	rules, _ := tournamentRules(db.DB, m.TournamentID)
+	if rules.PenaltyWarnings != 1 || rules.PenaltyPoints != 1 {
+		t.Fatalf("expected the default penalty rules backfilled, got %d warnings and %d points", rules.PenaltyWarnings, rules.PenaltyPoints)
+	}
+
+	path := fmt.Sprintf("/matches/%d/score", m.ID)
+	foul := ScoreRequest{Penalty: models.PenaltyFoul, OffenderID: p1.ID}
+	serve(t, UpdateMatchScore, http.MethodPost, "/matches/{id}/score", path, foul) // Warning
+	w := serve(t, UpdateMatchScore, http.MethodPost, "/matches/{id}/score", path, foul)
+	var got models.Match
+	decode(t, w, &got)
+	if got.ScoreP2 != 1 {
+		t.Errorf("expected the second foul to score for player %d, got %d-%d", p2.ID, got.ScoreP1, got.ScoreP2)
+	}
+
+	// Penalties switched off on purpose stay off
+	db.DB.Model(&models.Tournament{}).Where("id = ?", m.TournamentID).Update("rule_penalty_points", 0)
+	if err := db.Migrate(db.DB); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if rules, _ := tournamentRules(db.DB, m.TournamentID); rules.PenaltyPoints != 0 {
+		t.Errorf("expected penalties left off, got %d points", rules.PenaltyPoints)
+	}
+}
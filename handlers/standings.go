@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"bbx_tournament/db"
+	"bbx_tournament/models"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// Qualification status of a standings row
+const (
+	StandingQualified  = "Qualified"
+	StandingEliminated = "Eliminated"
+	StandingAlive      = "Alive" // Can still go either way
+)
+
+// StandingsRow is one player's line in a group or Swiss table, counting that stage only
+type StandingsRow struct {
+	Rank             int    `json:"rank"`
+	RankDecidedBy    string `json:"rank_decided_by"`
+	ParticipantID    uint   `json:"participant_id"`
+	Nickname         string `json:"nickname"`
+	Club             string `json:"club"`
+	Wins             int    `json:"wins"`
+	Draws            int    `json:"draws"`
+	Losses           int    `json:"losses"`
+	Points           int    `json:"points"`
+	ScoreFor         int    `json:"score_for"`
+	ScoreAgainst     int    `json:"score_against"`
+	SpinFinishes     int    `json:"spin_finishes"`
+	BurstFinishes    int    `json:"burst_finishes"`
+	OverFinishes     int    `json:"over_finishes"`
+	XtremeFinishes   int    `json:"xtreme_finishes"`
+	MatchesRemaining int    `json:"matches_remaining"`
+	Status           string `json:"status"` // Qualified, Eliminated, Alive
+}
+
+// GroupStandings is the ordered table of one group, or of the single Swiss pool
+type GroupStandings struct {
+	Group      string         `json:"group"`
+	Qualifiers int            `json:"qualifiers"` // Places that advance to the bracket
+	Rows       []StandingsRow `json:"rows"`
+}
+
+// GetStandings returns the ordered table of every group with qualification markers
+func GetStandings(w http.ResponseWriter, r *http.Request) {
+	tourIDStr := chi.URLParam(r, "id")
+	tourID, _ := strconv.Atoi(tourIDStr)
+
+	var t models.Tournament
+	if result := db.DB.Preload("Matches.Rounds").Preload("TournamentParticipants.Participant").First(&t, tourID); result.Error != nil {
+		http.Error(w, "Tournament not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(groupTables(t))
+}
+
+// rebuildStandings recomputes every TournamentParticipant counter from the match and round history.
+// Call it inside the same transaction as the score change so standings never drift.
+func rebuildStandings(tx *gorm.DB, tournamentID uint) error {
+	rules, err := tournamentRules(tx, tournamentID)
+	if err != nil {
+		return err
+	}
+
+	var participants []models.TournamentParticipant
+	if err := tx.Where("tournament_id = ?", tournamentID).Find(&participants).Error; err != nil {
+		return err
+	}
+
+	var matches []models.Match
+	if err := tx.Preload("Rounds").Where("tournament_id = ?", tournamentID).Find(&matches).Error; err != nil {
+		return err
+	}
+
+	// Group and Swiss places only count matches of that stage
+	var poolMatches []models.Match
+	for _, m := range matches {
+		if !models.IsBracketPhase(m.Phase) {
+			poolMatches = append(poolMatches, m)
+		}
+	}
+	pool := tallyStandings(participants, poolMatches, rules)
+	rankStandings(pool, poolMatches, rules)
+
+	for i, tp := range tallyStandings(participants, matches, rules) {
+		if err := tx.Model(&models.TournamentParticipant{}).Where("id = ?", tp.ID).Updates(map[string]interface{}{
+			"wins":             tp.Wins,
+			"losses":           tp.Losses,
+			"draws":            tp.Draws,
+			"points":           tp.Points,
+			"spin_finishes":    tp.SpinFinishes,
+			"burst_finishes":   tp.BurstFinishes,
+			"over_finishes":    tp.OverFinishes,
+			"xtreme_finishes":  tp.XtremeFinishes,
+			"buchholz":         tp.Buchholz,
+			"opponent_win_pct": tp.OpponentWinPct,
+			"score_for":        tp.ScoreFor,
+			"score_against":    tp.ScoreAgainst,
+			"rank":             pool[i].Rank,
+			"rank_decided_by":  pool[i].RankDecidedBy,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tallyStandings returns the participants with their counters derived from scratch
+func tallyStandings(participants []models.TournamentParticipant, matches []models.Match, rules models.RuleSet) []models.TournamentParticipant {
+	byPlayer := make(map[uint]*models.TournamentParticipant, len(participants))
+	out := make([]models.TournamentParticipant, len(participants))
+	for i, tp := range participants {
+		tp.Wins, tp.Losses, tp.Draws, tp.Points = 0, 0, 0, 0
+		tp.SpinFinishes, tp.BurstFinishes, tp.OverFinishes, tp.XtremeFinishes = 0, 0, 0, 0
+		tp.ScoreFor, tp.ScoreAgainst = 0, 0
+		out[i] = tp
+		byPlayer[tp.ParticipantID] = &out[i]
+	}
+
+	for _, m := range matches {
+		if m.Forfeit != "" {
+			// Only the result counts, not the rounds or scores it was given on
+			tallyForfeit(byPlayer, m, rules)
+			continue
+		}
+
+		// Every round counts toward the finish breakdown of whoever took it
+		for _, round := range m.Rounds {
+			if tp, ok := byPlayer[round.WinnerID]; ok {
+				addFinish(tp, round.WinType)
+			}
+		}
+
+		if m.IsBye {
+			// A Swiss bye scores as a win, bracket byes only move the player on
+			if tp, ok := byPlayer[m.Player1ID]; ok && m.Phase == models.PhaseSwiss {
+				tp.Wins++
+				tp.Points += rules.MatchWinPoints
+			}
+			continue
+		}
+		if tp, ok := byPlayer[m.Player1ID]; ok {
+			tp.ScoreFor += m.ScoreP1
+			tp.ScoreAgainst += m.ScoreP2
+		}
+		if tp, ok := byPlayer[m.Player2ID]; ok {
+			tp.ScoreFor += m.ScoreP2
+			tp.ScoreAgainst += m.ScoreP1
+		}
+		if m.IsDraw {
+			for _, pid := range []uint{m.Player1ID, m.Player2ID} {
+				if tp, ok := byPlayer[pid]; ok {
+					tp.Draws++
+					tp.Points += rules.DrawPoints
+				}
+			}
+			continue
+		}
+		if m.WinnerID == nil {
+			continue
+		}
+		loserID := m.Player1ID
+		if *m.WinnerID == m.Player1ID {
+			loserID = m.Player2ID
+		}
+		if tp, ok := byPlayer[*m.WinnerID]; ok {
+			tp.Wins++
+			tp.Points += rules.MatchWinPoints
+		}
+		if tp, ok := byPlayer[loserID]; ok {
+			tp.Losses++
+		}
+	}
+
+	swissTiebreakers(out, matches, rules)
+	return out
+}
+
+// tallyForfeit records a forfeited match as a win for the side still standing and a loss for
+// whoever forfeited, both sides when neither is left
+func tallyForfeit(byPlayer map[uint]*models.TournamentParticipant, m models.Match, rules models.RuleSet) {
+	for _, pid := range []uint{m.Player1ID, m.Player2ID} {
+		tp, ok := byPlayer[pid]
+		if !ok {
+			continue
+		}
+		if m.WinnerID != nil && *m.WinnerID == pid {
+			tp.Wins++
+			tp.Points += rules.MatchWinPoints
+		} else {
+			tp.Losses++
+		}
+	}
+}
+
+// addFinish bumps the finish counter matching a round's win type
+func addFinish(tp *models.TournamentParticipant, winType string) {
+	switch winType {
+	case "Spin":
+		tp.SpinFinishes++
+	case "Burst":
+		tp.BurstFinishes++
+	case "Over", "Out":
+		tp.OverFinishes++
+	case "Xtreme":
+		tp.XtremeFinishes++
+	}
+}
+
+// groupTables builds the group or Swiss tables from the tournament's pool matches
+func groupTables(t models.Tournament) []GroupStandings {
+	var poolMatches []models.Match
+	for _, m := range t.Matches {
+		if !models.IsBracketPhase(m.Phase) {
+			poolMatches = append(poolMatches, m)
+		}
+	}
+	participants := tallyStandings(t.TournamentParticipants, poolMatches, t.Rules)
+	pools := rankStandings(participants, poolMatches, t.Rules)
+
+	// Swiss rounds not yet paired still count as matches to play
+	swissLeft := 0
+	if t.Format == models.FormatSwiss {
+		played := 0
+		for _, m := range poolMatches {
+			if m.Round > played {
+				played = m.Round
+			}
+		}
+		swissLeft = swissRoundCount(t, activePlayers(participants)) - played
+		if swissLeft < 0 {
+			swissLeft = 0
+		}
+	}
+
+	// Once the bracket exists, seeds are the final word on who qualified
+	bracketSeeded := t.Status == models.StatusBracketInProgress || t.Status == models.StatusFinished
+
+	var tables []GroupStandings
+	for label, pool := range pools {
+		qualifiers := qualifiersPerGroup(t)
+		if label == models.PhaseSwiss {
+			qualifiers = swissTopCut(t, len(pool.ordered))
+		}
+		if qualifiers > len(pool.ordered) {
+			qualifiers = len(pool.ordered)
+		}
+
+		remaining := make(map[uint]int)
+		for _, m := range poolMatches {
+			if m.Phase == label && !m.IsBye && !m.Decided() {
+				remaining[m.Player1ID]++
+				remaining[m.Player2ID]++
+			}
+		}
+
+		statuses := qualificationStatus(pool, remaining, swissLeft, qualifiers, t.Rules)
+
+		table := GroupStandings{Group: label, Qualifiers: qualifiers}
+		for i, tp := range pool.ordered {
+			status := statuses[i]
+			if tp.Withdrawn {
+				status = StandingEliminated
+			}
+			if bracketSeeded {
+				status = StandingEliminated
+				if tp.Seed > 0 {
+					status = StandingQualified
+				}
+			}
+			table.Rows = append(table.Rows, StandingsRow{
+				Rank:             tp.Rank,
+				RankDecidedBy:    tp.RankDecidedBy,
+				ParticipantID:    tp.ParticipantID,
+				Nickname:         tp.Participant.Nickname,
+				Club:             tp.Participant.Club,
+				Wins:             tp.Wins,
+				Draws:            tp.Draws,
+				Losses:           tp.Losses,
+				Points:           tp.Points,
+				ScoreFor:         tp.ScoreFor,
+				ScoreAgainst:     tp.ScoreAgainst,
+				SpinFinishes:     tp.SpinFinishes,
+				BurstFinishes:    tp.BurstFinishes,
+				OverFinishes:     tp.OverFinishes,
+				XtremeFinishes:   tp.XtremeFinishes,
+				MatchesRemaining: remaining[tp.ParticipantID] + swissLeft,
+				Status:           status,
+			})
+		}
+		tables = append(tables, table)
+	}
+
+	// Group B before Group AA
+	sort.Slice(tables, func(i, j int) bool {
+		if len(tables[i].Group) != len(tables[j].Group) {
+			return len(tables[i].Group) < len(tables[j].Group)
+		}
+		return tables[i].Group < tables[j].Group
+	})
+	return tables
+}
+
+// qualificationStatus marks each player of a ranked pool. While matches remain, a player has
+// qualified once fewer than qualifiers others can still reach their points, and is out once
+// at least qualifiers others are already beyond their best possible total. Ties count
+// against the player since tiebreakers are not settled yet.
+func qualificationStatus(pool rankedPool, remaining map[uint]int, extraRounds, qualifiers int, rules models.RuleSet) []string {
+	statuses := make([]string, len(pool.ordered))
+	maxPoints := func(tp *models.TournamentParticipant) int {
+		return tp.Points + (remaining[tp.ParticipantID]+extraRounds)*rules.MatchWinPoints
+	}
+
+	complete := extraRounds == 0
+	for _, tp := range pool.ordered {
+		if remaining[tp.ParticipantID] > 0 {
+			complete = false
+		}
+	}
+
+	if complete {
+		// Players tied across the cut with nothing separating them stay alive
+		first, last := -1, -1
+		unresolved := func(k int) bool { return pool.splitName(pool.splits[k]) == models.TiebreakUnresolved }
+		if qualifiers > 0 && qualifiers < len(pool.ordered) && unresolved(qualifiers-1) {
+			first, last = qualifiers-1, qualifiers
+			for first > 0 && unresolved(first-1) {
+				first--
+			}
+			for last < len(pool.splits) && unresolved(last) {
+				last++
+			}
+		}
+		for i := range pool.ordered {
+			switch {
+			case first >= 0 && i >= first && i <= last:
+				statuses[i] = StandingAlive
+			case i < qualifiers:
+				statuses[i] = StandingQualified
+			default:
+				statuses[i] = StandingEliminated
+			}
+		}
+		return statuses
+	}
+
+	for i, tp := range pool.ordered {
+		threats, ahead := 0, 0
+		for _, other := range pool.ordered {
+			// Withdrawn players cannot take a qualifying place
+			if other == tp || other.Withdrawn {
+				continue
+			}
+			if maxPoints(other) >= tp.Points {
+				threats++
+			}
+			if other.Points > maxPoints(tp) {
+				ahead++
+			}
+		}
+		switch {
+		case threats < qualifiers:
+			statuses[i] = StandingQualified
+		case ahead >= qualifiers:
+			statuses[i] = StandingEliminated
+		default:
+			statuses[i] = StandingAlive
+		}
+	}
+	return statuses
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"bbx_tournament/models"
+	"testing"
+)
+
+func TestTallyStandings(t *testing.T) {
+	winner := uint(1)
+	participants := []models.TournamentParticipant{
+		{ParticipantID: 1, Wins: 5, Points: 15}, // Drifted counters are discarded
+		{ParticipantID: 2},
+		{ParticipantID: 3},
+	}
+	matches := []models.Match{
+		{
+			Player1ID: 1, Player2ID: 2, WinnerID: &winner,
+			Rounds: []models.MatchRound{
+				{WinnerID: 1, WinType: "Xtreme"},
+				{WinnerID: 2, WinType: "Spin"},
+				{WinnerID: 1, WinType: "Burst"},
+				{WinnerID: 1, WinType: "Over"},
+			},
+		},
+		{
+			// Still running, only the finishes count
+			Player1ID: 2, Player2ID: 3,
+			Rounds: []models.MatchRound{{WinnerID: 3, WinType: "Out"}},
+		},
+	}
+
+	got := tallyStandings(participants, matches, models.DefaultRuleSet())
+
+	expected := []struct {
+		wins, losses, points, spin, burst, over, xtreme int
+	}{
+		{1, 0, 3, 0, 1, 1, 1},
+		{0, 1, 0, 1, 0, 0, 0},
+		{0, 0, 0, 0, 0, 1, 0},
+	}
+	for i, e := range expected {
+		tp := got[i]
+		if tp.Wins != e.wins || tp.Losses != e.losses || tp.Points != e.points {
+			t.Errorf("participant %d: expected W%d L%d P%d, got W%d L%d P%d", tp.ParticipantID, e.wins, e.losses, e.points, tp.Wins, tp.Losses, tp.Points)
+		}
+		if tp.SpinFinishes != e.spin || tp.BurstFinishes != e.burst || tp.OverFinishes != e.over || tp.XtremeFinishes != e.xtreme {
+			t.Errorf("participant %d: unexpected finishes %+v", tp.ParticipantID, tp)
+		}
+	}
+}
+
+func TestGroupTablesQualification(t *testing.T) {
+	one, two, three := uint(1), uint(2), uint(3)
+	tournament := models.Tournament{
+		Status:             models.StatusInProgress,
+		QualifiersPerGroup: 2,
+		Rules:              models.DefaultRuleSet(),
+		TournamentParticipants: []models.TournamentParticipant{
+			{ParticipantID: 1, Group: "A"},
+			{ParticipantID: 2, Group: "A"},
+			{ParticipantID: 3, Group: "A"},
+			{ParticipantID: 4, Group: "A"},
+		},
+		Matches: []models.Match{
+			{Phase: "A", Player1ID: 1, Player2ID: 2, WinnerID: &one, ScoreP1: 7, ScoreP2: 3},
+			{Phase: "A", Player1ID: 1, Player2ID: 3, WinnerID: &one, ScoreP1: 7, ScoreP2: 2},
+			{Phase: "A", Player1ID: 1, Player2ID: 4, WinnerID: &one, ScoreP1: 7, ScoreP2: 0},
+			{Phase: "A", Player1ID: 2, Player2ID: 4, WinnerID: &two, ScoreP1: 7, ScoreP2: 1},
+			{Phase: "A", Player1ID: 3, Player2ID: 4, WinnerID: &three, ScoreP1: 7, ScoreP2: 5},
+			{Phase: "A", Player1ID: 2, Player2ID: 3},
+		},
+	}
+
+	tables := groupTables(tournament)
+	if len(tables) != 1 || len(tables[0].Rows) != 4 {
+		t.Fatalf("expected one table of four rows, got %+v", tables)
+	}
+
+	// 1 has 9 points and nobody else can pass 6; 4 cannot reach 3 with nothing left to play;
+	// 2 and 3 meet for the last place
+	expected := map[uint]struct {
+		status    string
+		remaining int
+	}{
+		1: {StandingQualified, 0},
+		2: {StandingAlive, 1},
+		3: {StandingAlive, 1},
+		4: {StandingEliminated, 0},
+	}
+	for _, row := range tables[0].Rows {
+		e := expected[row.ParticipantID]
+		if row.Status != e.status || row.MatchesRemaining != e.remaining {
+			t.Errorf("player %d: expected %s with %d left, got %s with %d left", row.ParticipantID, e.status, e.remaining, row.Status, row.MatchesRemaining)
+		}
+	}
+	if row := tables[0].Rows[0]; row.ParticipantID != 1 || row.ScoreFor != 21 || row.ScoreAgainst != 5 {
+		t.Errorf("unexpected leader row %+v", row)
+	}
+
+	// Once 2 wins the decider the table is final
+	tournament.Matches[5].WinnerID = &two
+	for _, row := range groupTables(tournament)[0].Rows {
+		qualified := row.ParticipantID <= 2
+		if (row.Status == StandingQualified) != qualified {
+			t.Errorf("player %d: unexpected final status %s", row.ParticipantID, row.Status)
+		}
+	}
+}
+
+func TestGroupTablesIgnoreWithdrawnPlayers(t *testing.T) {
+	one, four := uint(1), uint(4)
+	walkover := models.Match{Phase: "A", Player1ID: 1, Player2ID: 4}
+	forfeitMatch(&walkover, map[uint]bool{1: true}, models.ForfeitWithdrawal)
+	tournament := models.Tournament{
+		Status:             models.StatusInProgress,
+		QualifiersPerGroup: 2,
+		Rules:              models.DefaultRuleSet(),
+		TournamentParticipants: []models.TournamentParticipant{
+			{ParticipantID: 1, Group: "A", Withdrawn: true},
+			{ParticipantID: 2, Group: "A"},
+			{ParticipantID: 3, Group: "A"},
+			{ParticipantID: 4, Group: "A"},
+		},
+		Matches: []models.Match{
+			{Phase: "A", Player1ID: 1, Player2ID: 2, WinnerID: &one, ScoreP1: 7},
+			{Phase: "A", Player1ID: 1, Player2ID: 3, WinnerID: &one, ScoreP1: 7},
+			walkover,
+			{Phase: "A", Player1ID: 3, Player2ID: 4, WinnerID: &four, ScoreP2: 7},
+			{Phase: "A", Player1ID: 2, Player2ID: 3},
+			{Phase: "A", Player1ID: 2, Player2ID: 4},
+		},
+	}
+
+	// 1 left on 6 points: 4 is sure of a top two place among those still playing, and 3 can
+	// still tie 2 for the other by beating them
+	expected := map[uint]string{1: StandingEliminated, 2: StandingAlive, 3: StandingAlive, 4: StandingQualified}
+	for _, row := range groupTables(tournament)[0].Rows {
+		if row.Status != expected[row.ParticipantID] {
+			t.Errorf("player %d: expected %s, got %s", row.ParticipantID, expected[row.ParticipantID], row.Status)
+		}
+	}
+}
+
+func TestTallyStandingsCreditsDraws(t *testing.T) {
+	rules := models.DefaultRuleSet()
+	participants := []models.TournamentParticipant{{ParticipantID: 1}, {ParticipantID: 2}}
+	matches := []models.Match{{
+		Phase: "A", Player1ID: 1, Player2ID: 2, ScoreP1: 3, ScoreP2: 3, IsDraw: true,
+		Rounds: []models.MatchRound{
+			{WinnerID: 1, WinType: "Xtreme"},
+			{WinType: models.WinTypeDraw},
+			{WinnerID: 2, WinType: "Xtreme"},
+		},
+	}}
+
+	for _, tp := range tallyStandings(participants, matches, rules) {
+		if tp.Draws != 1 || tp.Points != rules.DrawPoints || tp.Wins != 0 || tp.Losses != 0 {
+			t.Errorf("player %d: expected one draw worth %d points, got %+v", tp.ParticipantID, rules.DrawPoints, tp)
+		}
+		if tp.XtremeFinishes != 1 {
+			t.Errorf("player %d: draw rounds must not count as finishes, got %d Xtreme", tp.ParticipantID, tp.XtremeFinishes)
+		}
+	}
+}
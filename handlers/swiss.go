@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"bbx_tournament/models"
+	"math/bits"
+	"sort"
+)
+
+// swissRoundCount returns how many Swiss rounds a tournament plays with players still in it
+func swissRoundCount(t models.Tournament, players int) int {
+	if t.SwissRounds > 0 {
+		return t.SwissRounds
+	}
+	if players < 1 {
+		players = 1
+	}
+	// Enough rounds for a single undefeated player
+	rounds := bits.Len(uint(players - 1))
+	if rounds < 1 {
+		rounds = 1
+	}
+	return rounds
+}
+
+// activePlayers counts the participants who have not withdrawn
+func activePlayers(participants []models.TournamentParticipant) int {
+	return len(participants) - len(withdrawnPlayers(participants))
+}
+
+// swissTopCut returns how many Swiss players advance to the bracket
+func swissTopCut(t models.Tournament, players int) int {
+	cut := t.TopCut
+	if cut <= 0 {
+		cut = 8
+	}
+	if cut > players {
+		cut = players
+	}
+	return cut
+}
+
+// sortSwissStandings orders players by points, then Buchholz, opponent win rate and wins
+func sortSwissStandings(participants []models.TournamentParticipant) {
+	sort.SliceStable(participants, func(i, j int) bool {
+		a, b := participants[i], participants[j]
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		if a.Buchholz != b.Buchholz {
+			return a.Buchholz > b.Buchholz
+		}
+		if a.OpponentWinPct != b.OpponentWinPct {
+			return a.OpponentWinPct > b.OpponentWinPct
+		}
+		if a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+		return a.ParticipantID < b.ParticipantID
+	})
+}
+
+// nextSwissRound pairs the next Swiss round from the current standings and previous matches
+func nextSwissRound(tournamentID uint, participants []models.TournamentParticipant, matches []models.Match) []models.Match {
+	// Withdrawn players are no longer paired
+	var standings []models.TournamentParticipant
+	for _, tp := range participants {
+		if !tp.Withdrawn {
+			standings = append(standings, tp)
+		}
+	}
+	sortSwissStandings(standings)
+
+	round := 0
+	played := make(map[[2]uint]bool)
+	hadBye := make(map[uint]bool)
+	for _, m := range matches {
+		if m.Phase != models.PhaseSwiss {
+			continue
+		}
+		if m.Round > round {
+			round = m.Round
+		}
+		if m.IsBye {
+			hadBye[m.Player1ID] = true
+			continue
+		}
+		played[[2]uint{m.Player1ID, m.Player2ID}] = true
+		played[[2]uint{m.Player2ID, m.Player1ID}] = true
+	}
+
+	ranked := make([]uint, len(standings))
+	for i, tp := range standings {
+		ranked[i] = tp.ParticipantID
+	}
+
+	pairs, bye := pairSwiss(ranked, played, hadBye)
+
+	var next []models.Match
+	for i, p := range pairs {
+		next = append(next, models.Match{
+			TournamentID: tournamentID,
+			Player1ID:    p[0],
+			Player2ID:    p[1],
+			Phase:        models.PhaseSwiss,
+			Round:        round + 1,
+			Slot:         i,
+		})
+	}
+	if bye != 0 {
+		winner := bye
+		next = append(next, models.Match{
+			TournamentID: tournamentID,
+			Player1ID:    bye,
+			WinnerID:     &winner,
+			IsBye:        true,
+			Phase:        models.PhaseSwiss,
+			Round:        round + 1,
+			Slot:         len(pairs),
+		})
+	}
+	return next
+}
+
+// pairSwiss pairs players ranked best first with the closest-ranked opponent they have not met.
+// With an odd count the lowest-ranked player without a bye sits out. Rematches are only
+// allowed when no rematch-free pairing exists.
+func pairSwiss(ranked []uint, played map[[2]uint]bool, hadBye map[uint]bool) ([][2]uint, uint) {
+	var byeCandidates []uint
+	if len(ranked)%2 == 1 {
+		for i := len(ranked) - 1; i >= 0; i-- {
+			if !hadBye[ranked[i]] {
+				byeCandidates = append(byeCandidates, ranked[i])
+			}
+		}
+		if len(byeCandidates) == 0 {
+			// Everyone already had one, start over from the bottom
+			byeCandidates = append(byeCandidates, ranked[len(ranked)-1])
+		}
+	} else {
+		byeCandidates = []uint{0}
+	}
+
+	for _, avoidRematches := range []bool{true, false} {
+		for _, bye := range byeCandidates {
+			var pool []uint
+			for _, pid := range ranked {
+				if pid != bye {
+					pool = append(pool, pid)
+				}
+			}
+			budget := swissPairingBudget
+			if pairs, ok := pairWithoutRematch(pool, played, avoidRematches, &budget); ok {
+				return pairs, bye
+			}
+		}
+	}
+	return nil, 0
+}
+
+// swissPairingBudget caps the backtracking per attempt so a field that cannot avoid
+// rematches falls back quickly instead of exploring every pairing
+const swissPairingBudget = 100000
+
+// pairWithoutRematch backtracks over the pool, pairing the best remaining player first
+func pairWithoutRematch(pool []uint, played map[[2]uint]bool, avoidRematches bool, budget *int) ([][2]uint, bool) {
+	if len(pool) == 0 {
+		return nil, true
+	}
+	if *budget <= 0 {
+		return nil, false
+	}
+	*budget--
+
+	first := pool[0]
+	for i := 1; i < len(pool); i++ {
+		opponent := pool[i]
+		if avoidRematches && played[[2]uint{first, opponent}] {
+			continue
+		}
+
+		rest := make([]uint, 0, len(pool)-2)
+		rest = append(rest, pool[1:i]...)
+		rest = append(rest, pool[i+1:]...)
+		if pairs, ok := pairWithoutRematch(rest, played, avoidRematches, budget); ok {
+			return append([][2]uint{{first, opponent}}, pairs...), true
+		}
+	}
+	return nil, false
+}
+
+// swissTiebreakers fills Buchholz and opponent win rate, counting Swiss matches only so the
+// top cut bracket does not shift the Swiss ranking afterwards
+func swissTiebreakers(participants []models.TournamentParticipant, matches []models.Match, rules models.RuleSet) {
+	// A draw counts as half a win toward the win rate
+	type record struct {
+		wins   float64
+		games  int
+		points int
+	}
+	records := make(map[uint]*record)
+	get := func(pid uint) *record {
+		if records[pid] == nil {
+			records[pid] = &record{}
+		}
+		return records[pid]
+	}
+
+	opponents := make(map[uint][]uint)
+	for _, m := range matches {
+		if m.Phase != models.PhaseSwiss || !m.Decided() {
+			continue
+		}
+		if m.IsDraw {
+			for _, pid := range []uint{m.Player1ID, m.Player2ID} {
+				get(pid).wins += 0.5
+				get(pid).games++
+				get(pid).points += rules.DrawPoints
+			}
+			opponents[m.Player1ID] = append(opponents[m.Player1ID], m.Player2ID)
+			opponents[m.Player2ID] = append(opponents[m.Player2ID], m.Player1ID)
+			continue
+		}
+		if m.WinnerID == nil {
+			// Both sides forfeited, a loss for each
+			get(m.Player1ID).games++
+			get(m.Player2ID).games++
+			opponents[m.Player1ID] = append(opponents[m.Player1ID], m.Player2ID)
+			opponents[m.Player2ID] = append(opponents[m.Player2ID], m.Player1ID)
+			continue
+		}
+		winner := get(*m.WinnerID)
+		winner.wins++
+		winner.games++
+		winner.points += rules.MatchWinPoints
+		if m.IsBye {
+			continue
+		}
+		get(loserOf(m)).games++
+		opponents[m.Player1ID] = append(opponents[m.Player1ID], m.Player2ID)
+		opponents[m.Player2ID] = append(opponents[m.Player2ID], m.Player1ID)
+	}
+
+	for i := range participants {
+		tp := &participants[i]
+		tp.Buchholz = 0
+		tp.OpponentWinPct = 0
+
+		opps := opponents[tp.ParticipantID]
+		if len(opps) == 0 {
+			continue
+		}
+		total := 0.0
+		for _, oid := range opps {
+			opp := get(oid)
+			tp.Buchholz += opp.points
+			rate := 0.0
+			if opp.games > 0 {
+				rate = opp.wins / float64(opp.games)
+			}
+			if rate < 1.0/3 {
+				rate = 1.0 / 3
+			}
+			total += rate
+		}
+		tp.OpponentWinPct = total / float64(len(opps))
+	}
+}
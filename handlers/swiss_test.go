@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"bbx_tournament/models"
+	"math"
+	"testing"
+)
+
+func TestSwissRoundsAvoidRematches(t *testing.T) {
+	const players = 41
+	participants := make([]models.TournamentParticipant, players)
+	for i := range participants {
+		participants[i] = models.TournamentParticipant{ParticipantID: uint(i + 1), Group: models.PhaseSwiss}
+	}
+
+	tournament := models.Tournament{Format: models.FormatSwiss}
+	rounds := swissRoundCount(tournament, players)
+	if rounds != 6 {
+		t.Fatalf("expected 6 rounds for %d players, got %d", players, rounds)
+	}
+
+	var matches []models.Match
+	for round := 1; round <= rounds; round++ {
+		next := nextSwissRound(1, participants, matches)
+		if len(next) != players/2+1 {
+			t.Fatalf("round %d: expected %d matches, got %d", round, players/2+1, len(next))
+		}
+		for i := range next {
+			if next[i].Round != round {
+				t.Fatalf("round %d: match numbered %d", round, next[i].Round)
+			}
+			if next[i].WinnerID == nil {
+				// Lower ID always wins
+				winner := next[i].Player1ID
+				if next[i].Player2ID < winner {
+					winner = next[i].Player2ID
+				}
+				next[i].WinnerID = &winner
+			}
+		}
+		matches = append(matches, next...)
+		participants = tallyStandings(participants, matches, models.DefaultRuleSet())
+	}
+
+	met := make(map[[2]uint]bool)
+	byes := make(map[uint]bool)
+	for _, m := range matches {
+		if m.IsBye {
+			if byes[m.Player1ID] {
+				t.Errorf("player %d got a second bye", m.Player1ID)
+			}
+			byes[m.Player1ID] = true
+			continue
+		}
+		key := [2]uint{m.Player1ID, m.Player2ID}
+		if m.Player2ID < m.Player1ID {
+			key = [2]uint{m.Player2ID, m.Player1ID}
+		}
+		if met[key] {
+			t.Errorf("players %d and %d met twice", key[0], key[1])
+		}
+		met[key] = true
+	}
+	if len(byes) != rounds {
+		t.Errorf("expected %d different bye players, got %d", rounds, len(byes))
+	}
+}
+
+func TestPairSwissByeGoesToLowestWithoutBye(t *testing.T) {
+	ranked := []uint{1, 2, 3, 4, 5}
+	pairs, bye := pairSwiss(ranked, map[[2]uint]bool{}, map[uint]bool{5: true})
+	if bye != 4 {
+		t.Errorf("expected player 4 to get the bye, got %d", bye)
+	}
+	if len(pairs) != 2 {
+		t.Errorf("expected two pairs, got %v", pairs)
+	}
+}
+
+func TestSwissTiebreakers(t *testing.T) {
+	one, two, three := uint(1), uint(2), uint(3)
+	participants := []models.TournamentParticipant{{ParticipantID: 1}, {ParticipantID: 2}, {ParticipantID: 3}, {ParticipantID: 4}}
+	matches := []models.Match{
+		{Phase: models.PhaseSwiss, Round: 1, Player1ID: 1, Player2ID: 2, WinnerID: &one},
+		{Phase: models.PhaseSwiss, Round: 1, Player1ID: 3, Player2ID: 4, WinnerID: &three},
+		{Phase: models.PhaseSwiss, Round: 2, Player1ID: 1, Player2ID: 3, WinnerID: &one},
+		{Phase: models.PhaseSwiss, Round: 2, Player1ID: 2, Player2ID: 4, WinnerID: &two},
+		// Bracket results do not count
+		{Phase: models.PhaseBracket, Round: 1, Player1ID: 4, Player2ID: 1, WinnerID: &two},
+	}
+
+	swissTiebreakers(participants, matches, models.DefaultRuleSet())
+
+	// Player 1 beat 2 (3 points, 50%) and 3 (3 points, 50%)
+	if participants[0].Buchholz != 6 {
+		t.Errorf("expected Buchholz 6, got %d", participants[0].Buchholz)
+	}
+	if math.Abs(participants[0].OpponentWinPct-0.5) > 1e-9 {
+		t.Errorf("expected opponent win rate 0.5, got %f", participants[0].OpponentWinPct)
+	}
+	// Player 4 lost to 3 (50%) and 2 (50%)
+	if participants[3].Buchholz != 6 {
+		t.Errorf("expected Buchholz 6, got %d", participants[3].Buchholz)
+	}
+	// Player 2 met 1 (100%) and 4 (0%, floored at a third)
+	if expected := (1 + 1.0/3) / 2; math.Abs(participants[1].OpponentWinPct-expected) > 1e-9 {
+		t.Errorf("expected opponent win rate %f, got %f", expected, participants[1].OpponentWinPct)
+	}
+}
+
+func TestSwissTiebreakersDoubleForfeit(t *testing.T) {
+	one := uint(1)
+	participants := []models.TournamentParticipant{{ParticipantID: 1}, {ParticipantID: 2}, {ParticipantID: 3}}
+	matches := []models.Match{
+		{Phase: models.PhaseSwiss, Round: 1, Player1ID: 1, Player2ID: 3, WinnerID: &one},
+		{Phase: models.PhaseSwiss, Round: 1, Player1ID: 2, Player2ID: 3},
+	}
+	forfeitMatch(&matches[1], map[uint]bool{2: true, 3: true}, models.ForfeitWithdrawal)
+
+	swissTiebreakers(participants, matches, models.DefaultRuleSet())
+
+	// Player 3 lost both, player 2 met 3 who has nothing
+	if participants[0].Buchholz != 0 || participants[1].Buchholz != 0 || participants[2].Buchholz != 3 {
+		t.Errorf("expected Buchholz 0/0/3, got %d/%d/%d", participants[0].Buchholz, participants[1].Buchholz, participants[2].Buchholz)
+	}
+}
+
+func TestSwissRoundCountLeavesOutWithdrawnPlayers(t *testing.T) {
+	participants := make([]models.TournamentParticipant, 9)
+	for i := range participants {
+		participants[i] = models.TournamentParticipant{ParticipantID: uint(i + 1), Withdrawn: i >= 4}
+	}
+	tournament := models.Tournament{Format: models.FormatSwiss}
+	if rounds := swissRoundCount(tournament, activePlayers(participants)); rounds != 2 {
+		t.Errorf("expected 2 rounds for the 4 players left, got %d", rounds)
+	}
+	if rounds := swissRoundCount(tournament, 0); rounds != 1 {
+		t.Errorf("expected a single round without players, got %d", rounds)
+	}
+}
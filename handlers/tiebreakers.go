@@ -0,0 +1,396 @@
+package handlers
+
+import (
+	"bbx_tournament/db"
+	"bbx_tournament/models"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// rankCriterion scores a player among the players still tied with them, higher ranks first
+type rankCriterion struct {
+	name  string
+	value func(tp *models.TournamentParticipant, tied []*models.TournamentParticipant) float64
+}
+
+// withdrawnLast puts players who left the event below everyone still playing
+var withdrawnLast = rankCriterion{"Withdrawn", func(tp *models.TournamentParticipant, _ []*models.TournamentParticipant) float64 {
+	if tp.Withdrawn {
+		return -1
+	}
+	return 0
+}}
+
+// groupCriteria returns points followed by the rule set's tiebreaker chain
+func groupCriteria(matches []models.Match, rules models.RuleSet) []rankCriterion {
+	criteria := []rankCriterion{withdrawnLast, {models.TiebreakPoints, func(tp *models.TournamentParticipant, _ []*models.TournamentParticipant) float64 {
+		return float64(tp.Points)
+	}}}
+
+	for _, name := range rules.TiebreakerChain() {
+		var value func(tp *models.TournamentParticipant, tied []*models.TournamentParticipant) float64
+		switch name {
+		case models.TiebreakHeadToHead:
+			value = func(tp *models.TournamentParticipant, tied []*models.TournamentParticipant) float64 {
+				return float64(headToHeadPoints(tp.ParticipantID, tied, matches, rules))
+			}
+		case models.TiebreakFinishDifferential:
+			value = func(tp *models.TournamentParticipant, _ []*models.TournamentParticipant) float64 {
+				return float64(tp.ScoreFor - tp.ScoreAgainst)
+			}
+		case models.TiebreakXtremeFinishes:
+			value = func(tp *models.TournamentParticipant, _ []*models.TournamentParticipant) float64 {
+				return float64(tp.XtremeFinishes)
+			}
+		case models.TiebreakWins:
+			value = func(tp *models.TournamentParticipant, _ []*models.TournamentParticipant) float64 {
+				return float64(tp.Wins)
+			}
+		case models.TiebreakPlayoff:
+			value = func(tp *models.TournamentParticipant, _ []*models.TournamentParticipant) float64 {
+				if tp.TiebreakOrder == 0 {
+					return math.Inf(-1)
+				}
+				return -float64(tp.TiebreakOrder)
+			}
+		default:
+			continue
+		}
+		criteria = append(criteria, rankCriterion{name, value})
+	}
+	return criteria
+}
+
+// swissCriteria mirrors sortSwissStandings
+func swissCriteria() []rankCriterion {
+	return []rankCriterion{
+		withdrawnLast,
+		{models.TiebreakPoints, func(tp *models.TournamentParticipant, _ []*models.TournamentParticipant) float64 {
+			return float64(tp.Points)
+		}},
+		{"Buchholz", func(tp *models.TournamentParticipant, _ []*models.TournamentParticipant) float64 {
+			return float64(tp.Buchholz)
+		}},
+		{"OpponentWinPct", func(tp *models.TournamentParticipant, _ []*models.TournamentParticipant) float64 {
+			return tp.OpponentWinPct
+		}},
+		{models.TiebreakWins, func(tp *models.TournamentParticipant, _ []*models.TournamentParticipant) float64 {
+			return float64(tp.Wins)
+		}},
+	}
+}
+
+// headToHeadPoints returns the standings points a player took from decided or drawn matches against the other tied players
+func headToHeadPoints(pid uint, tied []*models.TournamentParticipant, matches []models.Match, rules models.RuleSet) int {
+	among := make(map[uint]bool, len(tied))
+	for _, tp := range tied {
+		among[tp.ParticipantID] = true
+	}
+
+	points := 0
+	for _, m := range matches {
+		if m.IsBye || !m.Decided() || !among[m.Player1ID] || !among[m.Player2ID] {
+			continue
+		}
+		if m.IsDraw && (m.Player1ID == pid || m.Player2ID == pid) {
+			points += rules.DrawPoints
+		} else if m.WinnerID != nil && *m.WinnerID == pid {
+			points += rules.MatchWinPoints
+		}
+	}
+	return points
+}
+
+// rankedPool is a group or Swiss pool in final order. splits[i] is the index of the criterion
+// that put ordered[i] ahead of ordered[i+1], len(criteria) when nothing did.
+type rankedPool struct {
+	ordered  []*models.TournamentParticipant
+	splits   []int
+	criteria []rankCriterion
+}
+
+// splitName names the criterion at a split level
+func (p rankedPool) splitName(level int) string {
+	if level == len(p.criteria) {
+		return models.TiebreakUnresolved
+	}
+	return p.criteria[level].name
+}
+
+// rankPools orders every group or Swiss pool. It expects counters tallied from pool matches only.
+func rankPools(participants []models.TournamentParticipant, matches []models.Match, rules models.RuleSet) map[string]rankedPool {
+	members := make(map[string][]*models.TournamentParticipant)
+	for i := range participants {
+		if tp := &participants[i]; tp.Group != "" {
+			members[tp.Group] = append(members[tp.Group], tp)
+		}
+	}
+
+	pools := make(map[string]rankedPool, len(members))
+	for label, pool := range members {
+		var poolMatches []models.Match
+		for _, m := range matches {
+			if m.Phase == label {
+				poolMatches = append(poolMatches, m)
+			}
+		}
+		criteria := groupCriteria(poolMatches, rules)
+		if label == models.PhaseSwiss {
+			criteria = swissCriteria()
+		}
+		ordered, splits := rankTied(pool, criteria, 0)
+		pools[label] = rankedPool{ordered: ordered, splits: splits, criteria: criteria}
+	}
+	return pools
+}
+
+// rankStandings fills Rank and RankDecidedBy and returns the ranked pools. A player's place is
+// decided by the later of the criteria separating them from the players right above and below.
+func rankStandings(participants []models.TournamentParticipant, matches []models.Match, rules models.RuleSet) map[string]rankedPool {
+	for i := range participants {
+		participants[i].Rank, participants[i].RankDecidedBy = 0, ""
+	}
+
+	pools := rankPools(participants, matches, rules)
+	for _, pool := range pools {
+		for i, tp := range pool.ordered {
+			tp.Rank = i + 1
+			if len(pool.splits) == 0 {
+				continue
+			}
+			level := -1
+			if i > 0 {
+				level = pool.splits[i-1]
+			}
+			if i < len(pool.splits) && pool.splits[i] > level {
+				level = pool.splits[i]
+			}
+			tp.RankDecidedBy = pool.splitName(level)
+			// Name the recorded method rather than the criterion
+			if tp.RankDecidedBy == models.TiebreakPlayoff && tp.TiebreakMethod != "" {
+				tp.RankDecidedBy = tp.TiebreakMethod
+			}
+		}
+	}
+	return pools
+}
+
+// rankTied orders players on criteria[level], breaking remaining ties with the next criteria
+func rankTied(tied []*models.TournamentParticipant, criteria []rankCriterion, level int) ([]*models.TournamentParticipant, []int) {
+	if len(tied) < 2 {
+		return tied, nil
+	}
+	if level == len(criteria) {
+		ordered := append([]*models.TournamentParticipant{}, tied...)
+		sort.Slice(ordered, func(i, j int) bool { return ordered[i].ParticipantID < ordered[j].ParticipantID })
+		splits := make([]int, len(ordered)-1)
+		for i := range splits {
+			splits[i] = level
+		}
+		return ordered, splits
+	}
+
+	// Head-to-head is scored among exactly the players tied at this level
+	values := make(map[uint]float64, len(tied))
+	for _, tp := range tied {
+		values[tp.ParticipantID] = criteria[level].value(tp, tied)
+	}
+	sorted := append([]*models.TournamentParticipant{}, tied...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return values[sorted[i].ParticipantID] > values[sorted[j].ParticipantID]
+	})
+
+	var ordered []*models.TournamentParticipant
+	var splits []int
+	for start := 0; start < len(sorted); {
+		end := start + 1
+		for end < len(sorted) && values[sorted[end].ParticipantID] == values[sorted[start].ParticipantID] {
+			end++
+		}
+		if start > 0 {
+			splits = append(splits, level)
+		}
+		block, blockSplits := rankTied(sorted[start:end], criteria, level+1)
+		ordered = append(ordered, block...)
+		splits = append(splits, blockSplits...)
+		start = end
+	}
+	return ordered, splits
+}
+
+// unresolvedCut returns the first group where nothing separates the last qualifier from the
+// next player. Call it before the bracket starts, while the counters are still group-only.
+func unresolvedCut(participants []models.TournamentParticipant, matches []models.Match, rules models.RuleSet, perGroup int) string {
+	pools := rankPools(participants, matches, rules)
+
+	labels := make([]string, 0, len(pools))
+	for label := range pools {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		p := pools[label]
+		if len(p.ordered) > perGroup && p.splitName(p.splits[perGroup-1]) == models.TiebreakUnresolved {
+			return label
+		}
+	}
+	return ""
+}
+
+// tiedBlock reports whether the players are exactly the players of the group that only a
+// playoff or coin flip can separate, ignoring what was recorded between them before
+func tiedBlock(participants []models.TournamentParticipant, matches []models.Match, rules models.RuleSet, group string, pids []uint) bool {
+	listed := make(map[uint]bool, len(pids))
+	for _, pid := range pids {
+		listed[pid] = true
+	}
+	pool := tallyStandings(participants, matches, rules)
+	for i := range pool {
+		if listed[pool[i].ParticipantID] {
+			pool[i].TiebreakOrder, pool[i].TiebreakMethod = 0, ""
+		}
+	}
+
+	p, ok := rankPools(pool, matches, rules)[group]
+	if !ok {
+		return false
+	}
+	// Splits at this level or deeper leave the players tied on everything else
+	level := len(p.criteria)
+	for i, c := range p.criteria {
+		if c.name == models.TiebreakPlayoff {
+			level = i
+		}
+	}
+
+	first, last := -1, -1
+	for i, tp := range p.ordered {
+		if listed[tp.ParticipantID] {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 || last-first+1 != len(pids) {
+		return false
+	}
+	for i := first; i < last; i++ {
+		if p.splits[i] < level {
+			return false
+		}
+	}
+	if first > 0 && p.splits[first-1] >= level {
+		return false
+	}
+	return last == len(p.splits) || p.splits[last] < level
+}
+
+// TiebreakRequest records how a tie among group players was settled
+type TiebreakRequest struct {
+	Method         string `json:"method"`          // Playoff, CoinFlip
+	ParticipantIDs []uint `json:"participant_ids"` // Playoff: finishing order, winner first
+}
+
+// RecordTiebreak stores a playoff result or flips a coin between tied players of one group
+func RecordTiebreak(w http.ResponseWriter, r *http.Request) {
+	tourIDStr := chi.URLParam(r, "id")
+	tourID, _ := strconv.Atoi(tourIDStr)
+
+	var t models.Tournament
+	if result := db.DB.Preload("Matches.Rounds").Preload("TournamentParticipants").First(&t, tourID); result.Error != nil {
+		http.Error(w, "Tournament not found", http.StatusNotFound)
+		return
+	}
+	if !checkTransition(w, t, models.ActionRecordTiebreak) {
+		return
+	}
+
+	var req TiebreakRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Method != models.TiebreakPlayoff && req.Method != models.TiebreakCoinFlip {
+		http.Error(w, "Method must be Playoff or CoinFlip", http.StatusBadRequest)
+		return
+	}
+	if len(req.ParticipantIDs) < 2 {
+		http.Error(w, "A tiebreak needs at least two players", http.StatusBadRequest)
+		return
+	}
+
+	byPlayer := make(map[uint]models.TournamentParticipant)
+	for _, tp := range t.TournamentParticipants {
+		byPlayer[tp.ParticipantID] = tp
+	}
+	group := ""
+	seen := make(map[uint]bool)
+	for _, pid := range req.ParticipantIDs {
+		tp, ok := byPlayer[pid]
+		if !ok {
+			http.Error(w, fmt.Sprintf("Participant %d is not in this tournament", pid), http.StatusBadRequest)
+			return
+		}
+		if seen[pid] {
+			http.Error(w, fmt.Sprintf("Participant %d is listed twice", pid), http.StatusBadRequest)
+			return
+		}
+		seen[pid] = true
+		if group == "" {
+			group = tp.Group
+		}
+		if tp.Group == "" || tp.Group != group {
+			http.Error(w, "Tied players must be in the same group", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var poolMatches []models.Match
+	for _, m := range t.Matches {
+		if !models.IsBracketPhase(m.Phase) {
+			poolMatches = append(poolMatches, m)
+		}
+	}
+	if !tiedBlock(t.TournamentParticipants, poolMatches, t.Rules, group, req.ParticipantIDs) {
+		http.Error(w, "Players are not tied with each other, or other players share the tie", http.StatusBadRequest)
+		return
+	}
+
+	order := append([]uint{}, req.ParticipantIDs...)
+	if req.Method == models.TiebreakCoinFlip {
+		rand.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+	}
+
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		for i, pid := range order {
+			if err := tx.Model(&models.TournamentParticipant{}).Where("id = ?", byPlayer[pid].ID).Updates(map[string]interface{}{
+				"tiebreak_order":  i + 1,
+				"tiebreak_method": req.Method,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		if err := recordTransition(tx, t, t.Status, models.ActionRecordTiebreak); err != nil {
+			return err
+		}
+		return rebuildStandings(tx, t.ID)
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var standings []models.TournamentParticipant
+	db.DB.Preload("Participant").Where("tournament_id = ? AND \"group\" = ?", t.ID, group).Order("rank ASC").Find(&standings)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(standings)
+}
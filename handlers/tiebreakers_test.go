@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"bbx_tournament/models"
+	"testing"
+)
+
+func rankedOrder(participants []models.TournamentParticipant) map[uint]models.TournamentParticipant {
+	byPlayer := make(map[uint]models.TournamentParticipant)
+	for _, tp := range participants {
+		byPlayer[tp.ParticipantID] = tp
+	}
+	return byPlayer
+}
+
+func TestRankStandingsTiebreakerChain(t *testing.T) {
+	one, two, three, four := uint(1), uint(2), uint(3), uint(4)
+	participants := []models.TournamentParticipant{
+		{ParticipantID: 1, Group: "A"},
+		{ParticipantID: 2, Group: "A"},
+		{ParticipantID: 3, Group: "A"},
+		{ParticipantID: 4, Group: "A"},
+	}
+	matches := []models.Match{
+		// 1, 2 and 3 all finish on two wins
+		{Phase: "A", Player1ID: 1, Player2ID: 2, WinnerID: &two, ScoreP1: 5, ScoreP2: 7},
+		{Phase: "A", Player1ID: 1, Player2ID: 3, WinnerID: &one, ScoreP1: 7, ScoreP2: 0},
+		{Phase: "A", Player1ID: 1, Player2ID: 4, WinnerID: &one, ScoreP1: 7, ScoreP2: 6},
+		{Phase: "A", Player1ID: 2, Player2ID: 3, WinnerID: &three, ScoreP1: 6, ScoreP2: 7},
+		{Phase: "A", Player1ID: 2, Player2ID: 4, WinnerID: &two, ScoreP1: 7, ScoreP2: 1},
+		{Phase: "A", Player1ID: 3, Player2ID: 4, WinnerID: &three, ScoreP1: 7, ScoreP2: 2},
+		// Bracket matches never move group places
+		{Phase: models.PhaseBracket, Player1ID: 4, Player2ID: 1, WinnerID: &four, ScoreP1: 10, ScoreP2: 0},
+	}
+	rules := models.DefaultRuleSet()
+
+	var pool []models.Match
+	for _, m := range matches {
+		if !models.IsBracketPhase(m.Phase) {
+			pool = append(pool, m)
+		}
+	}
+	participants = tallyStandings(participants, pool, rules)
+	rankStandings(participants, pool, rules)
+	byPlayer := rankedOrder(participants)
+
+	// Head-to-head is a cycle, so the finish differential decides: 2 (+7), 1 (+6), 3 (-1)
+	expected := []struct {
+		pid       uint
+		rank      int
+		decidedBy string
+	}{
+		{2, 1, models.TiebreakFinishDifferential},
+		{1, 2, models.TiebreakFinishDifferential},
+		{3, 3, models.TiebreakFinishDifferential},
+		{4, 4, models.TiebreakPoints},
+	}
+	for _, e := range expected {
+		tp := byPlayer[e.pid]
+		if tp.Rank != e.rank || tp.RankDecidedBy != e.decidedBy {
+			t.Errorf("player %d: expected rank %d by %s, got %d by %s", e.pid, e.rank, e.decidedBy, tp.Rank, tp.RankDecidedBy)
+		}
+	}
+}
+
+func TestRankStandingsHeadToHeadAndPlayoff(t *testing.T) {
+	one, three := uint(1), uint(3)
+	participants := []models.TournamentParticipant{
+		{ParticipantID: 1, Group: "B"},
+		{ParticipantID: 2, Group: "B"},
+		{ParticipantID: 3, Group: "B"},
+		{ParticipantID: 4, Group: "B"},
+	}
+	matches := []models.Match{
+		{Phase: "B", Player1ID: 1, Player2ID: 3, WinnerID: &one, ScoreP1: 7, ScoreP2: 6},
+		{Phase: "B", Player1ID: 3, Player2ID: 2, WinnerID: &three, ScoreP1: 7, ScoreP2: 0},
+	}
+	rules := models.DefaultRuleSet()
+	rules.Tiebreakers = []string{models.TiebreakHeadToHead}
+
+	participants = tallyStandings(participants, matches, rules)
+	rankStandings(participants, matches, rules)
+	byPlayer := rankedOrder(participants)
+
+	// 1 and 3 have a win each, 1 beat 3
+	if tp := byPlayer[1]; tp.Rank != 1 || tp.RankDecidedBy != models.TiebreakHeadToHead {
+		t.Errorf("player 1: got rank %d by %s", tp.Rank, tp.RankDecidedBy)
+	}
+	// 2 and 4 never met and tie on everything
+	for _, pid := range []uint{2, 4} {
+		if tp := byPlayer[pid]; tp.RankDecidedBy != models.TiebreakUnresolved {
+			t.Errorf("player %d: expected an unresolved tie, got %s", pid, tp.RankDecidedBy)
+		}
+	}
+	if group := unresolvedCut(participants, matches, rules, 2); group != "" {
+		t.Errorf("the cut after two is settled, got %q", group)
+	}
+	if group := unresolvedCut(participants, matches, rules, 3); group != "B" {
+		t.Errorf("expected the cut after three to be open in B, got %q", group)
+	}
+
+	// A coin flip settles it
+	for i := range participants {
+		switch participants[i].ParticipantID {
+		case 4:
+			participants[i].TiebreakOrder, participants[i].TiebreakMethod = 1, models.TiebreakCoinFlip
+		case 2:
+			participants[i].TiebreakOrder, participants[i].TiebreakMethod = 2, models.TiebreakCoinFlip
+		}
+	}
+	rankStandings(participants, matches, rules)
+	byPlayer = rankedOrder(participants)
+	if tp := byPlayer[4]; tp.Rank != 3 || tp.RankDecidedBy != models.TiebreakCoinFlip {
+		t.Errorf("player 4: expected rank 3 by CoinFlip, got %d by %s", tp.Rank, tp.RankDecidedBy)
+	}
+	if tp := byPlayer[2]; tp.Rank != 4 || tp.RankDecidedBy != models.TiebreakCoinFlip {
+		t.Errorf("player 2: expected rank 4 by CoinFlip, got %d by %s", tp.Rank, tp.RankDecidedBy)
+	}
+}
+
+func TestTiedBlock(t *testing.T) {
+	one, two, three := uint(1), uint(2), uint(3)
+	participants := []models.TournamentParticipant{
+		{ParticipantID: 1, Group: "A"},
+		{ParticipantID: 2, Group: "A"},
+		{ParticipantID: 3, Group: "A"},
+		{ParticipantID: 4, Group: "A"},
+	}
+	// 1, 2 and 3 beat each other in a circle by the same score, and all beat 4
+	matches := []models.Match{
+		{Phase: "A", Player1ID: 1, Player2ID: 2, WinnerID: &one, ScoreP1: 7, ScoreP2: 5},
+		{Phase: "A", Player1ID: 2, Player2ID: 3, WinnerID: &two, ScoreP1: 7, ScoreP2: 5},
+		{Phase: "A", Player1ID: 3, Player2ID: 1, WinnerID: &three, ScoreP1: 7, ScoreP2: 5},
+		{Phase: "A", Player1ID: 1, Player2ID: 4, WinnerID: &one, ScoreP1: 7, ScoreP2: 0},
+		{Phase: "A", Player1ID: 2, Player2ID: 4, WinnerID: &two, ScoreP1: 7, ScoreP2: 0},
+		{Phase: "A", Player1ID: 3, Player2ID: 4, WinnerID: &three, ScoreP1: 7, ScoreP2: 0},
+	}
+	rules := models.DefaultRuleSet()
+
+	cases := []struct {
+		pids []uint
+		want bool
+	}{
+		{[]uint{3, 1, 2}, true},
+		{[]uint{1, 2}, false}, // 3 shares the tie
+		{[]uint{3, 4}, false}, // 4 is last on points
+		{[]uint{1, 2, 3, 4}, false},
+	}
+	for _, c := range cases {
+		if got := tiedBlock(participants, matches, rules, "A", c.pids); got != c.want {
+			t.Errorf("tiedBlock(%v) = %v, want %v", c.pids, got, c.want)
+		}
+	}
+
+	// A playoff already recorded between them can be recorded again
+	for i := 0; i < 3; i++ {
+		participants[i].TiebreakOrder, participants[i].TiebreakMethod = i+1, models.TiebreakPlayoff
+	}
+	if !tiedBlock(participants, matches, rules, "A", []uint{1, 2, 3}) {
+		t.Error("expected a recorded playoff to be replaceable")
+	}
+	if tiedBlock(participants, matches, rules, "A", []uint{1, 2}) {
+		t.Error("expected a partial re-record to be refused while 3 keeps their recorded place")
+	}
+}
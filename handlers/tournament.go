@@ -4,6 +4,7 @@ import (
 	"bbx_tournament/db"
 	"bbx_tournament/models"
 	"encoding/json"
+	"io"
 	"net/http"
 	"sort"
 	"strconv"
@@ -31,17 +32,62 @@ func GetTournaments(w http.ResponseWriter, r *http.Request) {
 
 // CreateTournament creates a new tournament
 func CreateTournament(w http.ResponseWriter, r *http.Request) {
-	var t models.Tournament
+	// Start from the default rules so a partial "rules" object only overrides what it sets
+	t := models.Tournament{Rules: models.DefaultRuleSet()}
 	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if err := validateRuleSet(t.Rules); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Set defaults
 	if t.Date.IsZero() {
 		t.Date = time.Now()
 	}
-	t.Status = "Created"
+	t.Status = models.StatusCreated
+	if t.DeckRules == "" {
+		t.DeckRules = models.DeckRulesStandard
+	}
+	if !validDeckRules(t.DeckRules) {
+		http.Error(w, "Invalid deck rules", http.StatusBadRequest)
+		return
+	}
+	if t.Format == "" {
+		t.Format = models.FormatGroups
+	}
+	if t.Format != models.FormatGroups && t.Format != models.FormatSwiss {
+		http.Error(w, "Invalid format", http.StatusBadRequest)
+		return
+	}
+	if t.BracketFormat == "" {
+		t.BracketFormat = models.BracketSingleElimination
+	}
+	if t.BracketFormat != models.BracketSingleElimination && t.BracketFormat != models.BracketDoubleElimination {
+		http.Error(w, "Invalid bracket format", http.StatusBadRequest)
+		return
+	}
+	if t.ThirdPlaceMatch && t.BracketFormat != models.BracketSingleElimination {
+		http.Error(w, "A third place match is only played in single elimination", http.StatusBadRequest)
+		return
+	}
+	if t.GroupSize < 0 || t.GroupCount < 0 || t.QualifiersPerGroup < 0 {
+		http.Error(w, "Group settings cannot be negative", http.StatusBadRequest)
+		return
+	}
+	if t.GroupSize > 0 && t.GroupCount > 0 {
+		http.Error(w, "Set either group_size or group_count, not both", http.StatusBadRequest)
+		return
+	}
+	if t.GroupSeeding == "" {
+		t.GroupSeeding = models.SeedingJoined
+	}
+	if !validGroupSeeding(t.GroupSeeding) {
+		http.Error(w, "Invalid group seeding", http.StatusBadRequest)
+		return
+	}
 
 	if result := db.DB.Create(&t); result.Error != nil {
 		http.Error(w, result.Error.Error(), http.StatusInternalServerError)
@@ -63,8 +109,8 @@ func GetTournamentDetails(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var t models.Tournament
-	// Preload everything we might need
-	if result := db.DB.Preload("Matches.Player1").Preload("Matches.Player2").Preload("TournamentParticipants.Participant").First(&t, id); result.Error != nil {
+	// Preload everything we might need, including each player's registered decks
+	if result := db.DB.Preload("Matches.Player1").Preload("Matches.Player2").Preload("Matches.Rounds", orderedRounds).Preload("TournamentParticipants.Participant.Decks.Beyblades").Preload("TournamentParticipants.Deck.Beyblades").First(&t, id); result.Error != nil {
 		http.Error(w, "Tournament not found", http.StatusNotFound)
 		return
 	}
@@ -79,34 +125,67 @@ func AddParticipantToTournament(w http.ResponseWriter, r *http.Request) {
 	tourID, _ := strconv.Atoi(tourIDStr) // handle err
 
 	var data struct {
-		ParticipantID uint `json:"participant_id"`
+		ParticipantID uint  `json:"participant_id"`
+		DeckID        *uint `json:"deck_id"` // Optional, validated against the tournament's deck rules
 	}
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Transaction to safeguard
-	err := db.DB.Transaction(func(tx *gorm.DB) error {
-		var t models.Tournament
-		if err := tx.First(&t, tourID).Error; err != nil {
-			return err
+	var t models.Tournament
+	if err := db.DB.Preload("Matches").Preload("TournamentParticipants").First(&t, tourID).Error; err != nil {
+		http.Error(w, "Tournament not found", http.StatusNotFound)
+		return
+	}
+	// Groups and pairings are drawn from the sign-ups, a late player would have no matches
+	if !checkTransition(w, t, models.ActionAddParticipant) {
+		return
+	}
+
+	if data.DeckID != nil {
+		var deck models.Deck
+		if err := db.DB.Preload("Beyblades").Where("participant_id = ?", data.ParticipantID).First(&deck, *data.DeckID).Error; err != nil {
+			http.Error(w, "Deck not found", http.StatusNotFound)
+			return
+		}
+		rules := t.DeckRules
+		if rules == "" {
+			rules = models.DeckRulesStandard
+		}
+		if violations := validateDeck(deck.Beyblades, rules); len(violations) > 0 {
+			writeDeckViolations(w, rules, violations)
+			return
 		}
+	}
 
+	// Transaction to safeguard
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
 		// Check if already joined (using new association)
 		var existing models.TournamentParticipant
 		if err := tx.Where("tournament_id = ? AND participant_id = ?", tourID, data.ParticipantID).First(&existing).Error; err == nil {
-			return nil // Already joined, idempotent
+			// Already joined, idempotent apart from swapping the registered deck
+			if data.DeckID != nil {
+				return tx.Model(&existing).Update("deck_id", *data.DeckID).Error
+			}
+			return nil
 		}
 
 		tp := models.TournamentParticipant{
 			TournamentID:  uint(tourID),
 			ParticipantID: data.ParticipantID,
+			DeckID:        data.DeckID,
 			Group:         "", // Added later
 		}
 		if err := tx.Create(&tp).Error; err != nil {
 			return err
 		}
+		if err := tx.First(&tp.Participant, tp.ParticipantID).Error; err != nil {
+			return err
+		}
+		if err := recordEvent(tx, tp.TournamentID, models.EventParticipantAdded, tp); err != nil {
+			return err
+		}
 
 		// Maintain legacy compatibility if needed, or rely on TournamentParticipants
 		// Only adding to new table is fine if we update queries.
@@ -120,6 +199,7 @@ func AddParticipantToTournament(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	liveEvents.publish(uint(tourID))
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"status": "success"}`))
@@ -131,54 +211,93 @@ func GenerateGroups(w http.ResponseWriter, r *http.Request) {
 	tourID, _ := strconv.Atoi(tourIDStr)
 
 	var t models.Tournament
-	if result := db.DB.Preload("TournamentParticipants").First(&t, tourID); result.Error != nil {
+	if result := db.DB.Preload("Matches").Preload("TournamentParticipants.Participant").First(&t, tourID); result.Error != nil {
 		http.Error(w, "Tournament not found", http.StatusNotFound)
 		return
 	}
-
-	if t.Status != "Created" && t.Status != "GroupsGenerated" {
-		http.Error(w, "Tournament already started or finished", http.StatusBadRequest)
+	if !checkTransition(w, t, models.ActionGenerateGroups) {
 		return
 	}
 
 	participants := t.TournamentParticipants
 	n := len(participants)
-	if n < 2 {
-		http.Error(w, "Not enough participants", http.StatusBadRequest)
+
+	// Optional body overrides the sizing chosen at creation
+	var settings GroupSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	settings.apply(&t)
 
-	// Logic: Target ~10 players per group.
-	numGroups := 1
-	if n > 10 {
-		numGroups = (n + 9) / 10
+	if !validGroupSeeding(t.GroupSeeding) {
+		http.Error(w, "Invalid group seeding", http.StatusBadRequest)
+		return
 	}
 
-	groups := make([][]int, numGroups) // Indices of participants
-	for i := range participants {
-		groups[i%numGroups] = append(groups[i%numGroups], i)
+	// Swiss plays everyone in a single pool
+	var groups [][]int // Indices of participants
+	if t.GroupSeeding == models.SeedingManual && t.Format != models.FormatSwiss {
+		manual, err := manualGroups(t, participants, settings.ManualGroups)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		groups = manual
+	} else {
+		numGroups, err := planGroups(t, n)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var strength map[uint]seedStrength
+		switch t.GroupSeeding {
+		case models.SeedingRandom:
+			// Keep the stored seed so re-running gives the same draw
+			if t.DrawSeed == 0 {
+				t.DrawSeed = time.Now().UnixNano()
+			}
+		case models.SeedingRating, models.SeedingResults:
+			if strength, err = loadSeedStrength(t); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		groups = drawGroups(t, participants, numGroups, strength)
+		spreadClubs(groups, participants)
 	}
-
-	groupNames := []string{"A", "B", "C", "D", "E", "F", "G", "H"}
+	numGroups := len(groups)
 
 	if err := db.DB.Transaction(func(tx *gorm.DB) error {
 		for gIdx, indices := range groups {
-			groupLabel := groupNames[gIdx%len(groupNames)]
-			if n > 10 {
+			groupLabel := groupName(gIdx)
+			if t.Format == models.FormatSwiss {
+				groupLabel = models.PhaseSwiss
+			} else if numGroups > 1 {
 				groupLabel = "Group " + groupLabel
 			}
 			for _, pIdx := range indices {
-				if err := tx.Model(&participants[pIdx]).Update("group", groupLabel).Error; err != nil {
+				// A new draw voids tiebreaks recorded for the old groups
+				if err := tx.Model(&participants[pIdx]).Updates(map[string]interface{}{
+					"group":           groupLabel,
+					"tiebreak_order":  0,
+					"tiebreak_method": "",
+				}).Error; err != nil {
 					return err
 				}
 			}
 		}
-		t.Status = "GroupsGenerated"
-		return tx.Save(&t).Error
+		from := t.Status
+		t.Status = models.StatusGroupsGenerated
+		if err := tx.Save(&t).Error; err != nil {
+			return err
+		}
+		return recordTransition(tx, t, from, models.ActionGenerateGroups)
 	}); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	liveEvents.publish(t.ID)
 
 	// Refetch with preloads
 	db.DB.Preload("TournamentParticipants.Participant").First(&t, tourID)
@@ -194,30 +313,37 @@ func GenerateMatches(w http.ResponseWriter, r *http.Request) {
 
 	var t models.Tournament
 	// Preload nested to get Participant details
-	if result := db.DB.Preload("TournamentParticipants.Participant").First(&t, tourID); result.Error != nil {
+	if result := db.DB.Preload("Matches").Preload("TournamentParticipants.Participant").First(&t, tourID); result.Error != nil {
 		http.Error(w, "Tournament not found", http.StatusNotFound)
 		return
 	}
-
-	if t.Status != "GroupsGenerated" {
-		http.Error(w, "Groups must be generated first", http.StatusBadRequest)
+	if !checkTransition(w, t, models.ActionGenerateMatches) {
 		return
 	}
 
-	matches := generateMatchesFromGroups(t.ID, t.TournamentParticipants)
+	var matches []models.Match
+	if t.Format == models.FormatSwiss {
+		matches = nextSwissRound(t.ID, t.TournamentParticipants, nil)
+	} else {
+		matches = generateMatchesFromGroups(t.ID, t.TournamentParticipants)
+	}
 
 	err := db.DB.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Create(&matches).Error; err != nil {
+		if err := createMatches(tx, t, matches); err != nil {
+			return err
+		}
+		t.Status = models.StatusInProgress
+		if err := tx.Save(&t).Error; err != nil {
 			return err
 		}
-		t.Status = "InProgress"
-		return tx.Save(&t).Error
+		return recordTransition(tx, t, models.StatusGroupsGenerated, models.ActionGenerateMatches)
 	})
 
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	liveEvents.publish(t.ID)
 
 	// Refetch with preloads for proper response
 	db.DB.Preload("Matches.Player1").Preload("Matches.Player2").Preload("TournamentParticipants.Participant").First(&t, tourID)
@@ -246,102 +372,135 @@ func AdvanceTournamentPhase(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 1. Check if all matches in the current state are finished
-	allFinished := true
-	for _, m := range t.Matches {
-		if m.WinnerID == nil {
-			allFinished = false
-			break
-		}
-	}
-
-	if !allFinished {
-		http.Error(w, "Current phase matches are not all finished", http.StatusBadRequest)
+	// 1. Check the current phase is complete and, for groups, every qualifier is settled
+	if !checkTransition(w, t, models.ActionAdvance) {
 		return
 	}
+	from := t.Status
 
-	// 2. Logic based on current status
-	switch t.Status {
-	case "InProgress": // Transitioning from Group Stage to Bracket
-		// Group participants by group
-		grouped := make(map[string][]models.TournamentParticipant)
-		for _, tp := range t.TournamentParticipants {
-			if tp.Group != "" {
-				grouped[tp.Group] = append(grouped[tp.Group], tp)
+	// 2. Logic based on current status. The new matches, seeds, status and history entry are
+	// written together so a failed advance can simply be retried.
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		switch t.Status {
+		case models.StatusInProgress: // Transitioning from Group Stage to Bracket
+			var seeds []uint
+			if t.Format == models.FormatSwiss {
+				swissRounds := 0
+				for _, m := range t.Matches {
+					if m.Phase == models.PhaseSwiss && m.Round > swissRounds {
+						swissRounds = m.Round
+					}
+				}
+
+				if swissRounds < swissRoundCount(t, activePlayers(t.TournamentParticipants)) {
+					nextMatches := nextSwissRound(t.ID, t.TournamentParticipants, t.Matches)
+					if err := createMatches(tx, t, nextMatches); err != nil {
+						return err
+					}
+					break
+				}
+
+				// Swiss is over, the top cut goes to the bracket in standings order
+				// Withdrawn players cannot make the cut
+				var standings []models.TournamentParticipant
+				for _, tp := range t.TournamentParticipants {
+					if !tp.Withdrawn {
+						standings = append(standings, tp)
+					}
+				}
+				sortSwissStandings(standings)
+				for _, tp := range standings[:swissTopCut(t, len(standings))] {
+					seeds = append(seeds, tp.ParticipantID)
+				}
+			} else {
+				seeds = seedQualifiers(t.TournamentParticipants, qualifiersPerGroup(t))
 			}
-		}
 
-		var qualifiedIDs []uint
-		// Sort each group and take top 4
-		for _, members := range grouped {
-			sort.Slice(members, func(i, j int) bool {
-				if members[i].Points != members[j].Points {
-					return members[i].Points > members[j].Points
+			if len(seeds) < 2 {
+				t.Status = models.StatusFinished
+				break
+			}
+			phase := models.PhaseBracket
+			if t.BracketFormat == models.BracketDoubleElimination {
+				phase = models.PhaseWinnersBracket
+			}
+			if err := createMatches(tx, t, seedBracketMatches(t.ID, seeds, phase)); err != nil {
+				return err
+			}
+			for i, participantID := range seeds {
+				if err := tx.Model(&models.TournamentParticipant{}).
+					Where("tournament_id = ? AND participant_id = ?", t.ID, participantID).
+					Update("seed", i+1).Error; err != nil {
+					return err
 				}
-				// Tie-breaker: Wins
-				return members[i].Wins > members[j].Wins
-			})
-
-			// Take top 4 (or less if group is smaller)
-			limit := 4
-			if len(members) < limit {
-				limit = len(members)
 			}
-			for _, m := range members[:limit] {
-				qualifiedIDs = append(qualifiedIDs, m.ParticipantID)
+			t.Status = models.StatusBracketInProgress
+
+		case models.StatusBracketInProgress:
+			if t.BracketFormat == models.BracketDoubleElimination {
+				nextMatches, finished := nextDoubleEliminationMatches(t, t.Matches)
+				if finished {
+					t.Status = models.StatusFinished
+				} else if err := createMatches(tx, t, nextMatches); err != nil {
+					return err
+				}
+				break
 			}
-		}
 
-		if len(qualifiedIDs) < 2 {
-			t.Status = "Finished"
-		} else {
-			bracketMatches := generateBracketMatches(t.ID, qualifiedIDs, 1)
-			if err := db.DB.Create(&bracketMatches).Error; err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
+			// Find current max round
+			maxRound := 0
+			for _, m := range t.Matches {
+				if m.Phase == models.PhaseBracket && m.Round > maxRound {
+					maxRound = m.Round
+				}
 			}
-			t.Status = "BracketInProgress"
-		}
 
-	case "BracketInProgress":
-		// Find current max round
-		maxRound := 0
-		for _, m := range t.Matches {
-			if m.Phase == "Bracket" && m.Round > maxRound {
-				maxRound = m.Round
+			// Get winners of the current round, in bracket order so neighbours meet next
+			var current []models.Match
+			for _, m := range t.Matches {
+				if m.Phase == models.PhaseBracket && m.Round == maxRound {
+					current = append(current, m)
+				}
 			}
-		}
+			sort.SliceStable(current, func(i, j int) bool { return current[i].Slot < current[j].Slot })
 
-		// Get winners of the current round
-		var winners []uint
-		for _, m := range t.Matches {
-			if m.Phase == "Bracket" && m.Round == maxRound {
+			var winners []uint
+			for _, m := range current {
 				if m.WinnerID != nil {
 					winners = append(winners, *m.WinnerID)
 				}
 			}
-		}
 
-		if len(winners) > 1 {
-			// Generate next round
-			nextRoundMatches := generateBracketMatches(t.ID, winners, maxRound+1)
-			if err := db.DB.Create(&nextRoundMatches).Error; err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
+			if len(winners) > 1 {
+				// Generate next round, with the match for third alongside the final
+				nextRoundMatches := generateBracketMatches(t.ID, winners, maxRound+1, models.PhaseBracket)
+				if len(winners) == 2 {
+					nextRoundMatches = append(nextRoundMatches, thirdPlaceMatch(t, current, maxRound+1)...)
+				}
+				if err := createMatches(tx, t, nextRoundMatches); err != nil {
+					return err
+				}
+			} else {
+				// Tournament Finished
+				t.Status = models.StatusFinished
 			}
-		} else {
-			// Tournament Finished
-			t.Status = "Finished"
 		}
 
-	default:
-		t.Status = "Finished"
-	}
-
-	if err := db.DB.Save(&t).Error; err != nil {
+		if t.Status == models.StatusFinished {
+			if err := storePlacements(tx, t, t.Matches); err != nil {
+				return err
+			}
+		}
+		if err := tx.Save(&t).Error; err != nil {
+			return err
+		}
+		return recordTransition(tx, t, from, models.ActionAdvance)
+	})
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	liveEvents.publish(t.ID)
 
 	// Refetch with all preloads for the response
 	db.DB.Preload("Matches.Player1").Preload("Matches.Player2").Preload("TournamentParticipants.Participant").First(&t, tourID)
@@ -350,51 +509,75 @@ func AdvanceTournamentPhase(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(t)
 }
 
-// ResetTournament clears matches and resets participant stats and groups
+// ResetTournament clears matches and resets participant stats, groups and withdrawals
 func ResetTournament(w http.ResponseWriter, r *http.Request) {
 	tourIDStr := chi.URLParam(r, "id")
 	tourID, _ := strconv.Atoi(tourIDStr)
 
+	var t models.Tournament
+	if result := db.DB.Preload("Matches").Preload("TournamentParticipants").First(&t, tourID); result.Error != nil {
+		http.Error(w, "Tournament not found", http.StatusNotFound)
+		return
+	}
+	if !checkTransition(w, t, models.ActionReset) {
+		return
+	}
+	from := t.Status
+
 	err := db.DB.Transaction(func(tx *gorm.DB) error {
-		var t models.Tournament
-		if err := tx.First(&t, tourID).Error; err != nil {
+		// 1. Delete all matches for this tournament, with their round logs
+		played := tx.Model(&models.Match{}).Select("id").Where("tournament_id = ?", tourID)
+		if err := tx.Where("match_id IN (?)", played).Delete(&models.MatchRound{}).Error; err != nil {
 			return err
 		}
-
-		// 1. Delete all matches for this tournament
 		if err := tx.Where("tournament_id = ?", tourID).Delete(&models.Match{}).Error; err != nil {
 			return err
 		}
 
-		// 2. Reset all TournamentParticipant stats and group
+		// 2. Reset all TournamentParticipant stats and group, withdrawn players sign up again
 		if err := tx.Model(&models.TournamentParticipant{}).
 			Where("tournament_id = ?", tourID).
 			Updates(map[string]interface{}{
-				"group":           "",
-				"wins":            0,
-				"losses":          0,
-				"draws":           0,
-				"points":          0,
-				"spin_finishes":   0,
-				"burst_finishes":  0,
-				"over_finishes":   0,
-				"xtreme_finishes": 0,
+				"group":            "",
+				"seed":             0,
+				"wins":             0,
+				"losses":           0,
+				"draws":            0,
+				"points":           0,
+				"spin_finishes":    0,
+				"burst_finishes":   0,
+				"over_finishes":    0,
+				"xtreme_finishes":  0,
+				"buchholz":         0,
+				"opponent_win_pct": 0,
+				"score_for":        0,
+				"score_against":    0,
+				"rank":             0,
+				"rank_decided_by":  "",
+				"tiebreak_order":   0,
+				"tiebreak_method":  "",
+				"withdrawn":        false,
+				"placement":        0,
 			}).Error; err != nil {
 			return err
 		}
 
 		// 3. Reset tournament status
-		t.Status = "Created"
-		return tx.Save(&t).Error
+		t.Status = models.StatusCreated
+		if err := tx.Model(&t).Update("status", t.Status).Error; err != nil {
+			return err
+		}
+		return recordTransition(tx, t, from, models.ActionReset)
 	})
 
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	liveEvents.publish(t.ID)
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "Created"})
+	json.NewEncoder(w).Encode(map[string]string{"status": string(t.Status)})
 }
 
 // ArchiveTournament soft-deletes a tournament
@@ -4,6 +4,7 @@ import (
 	"bbx_tournament/db"
 	"bbx_tournament/handlers"
 	"fmt"
+	"log"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
@@ -13,6 +14,10 @@ import (
 
 func main() {
 	db.InitDB("tournament.db")
+	// Brackets started before matches were linked to their feeders
+	if err := handlers.BackfillFeeders(db.DB); err != nil {
+		log.Fatalf("Failed to link bracket feeders: %v", err)
+	}
 
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
@@ -22,7 +27,7 @@ func main() {
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"}, // For dev
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "Last-Event-ID"},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: true,
 		MaxAge:           300,
@@ -31,21 +36,42 @@ func main() {
 	r.Get("/participants", handlers.GetParticipants)
 	r.Get("/stats", handlers.GetLeagueStats)
 	r.Post("/participants", handlers.CreateParticipant)
+	r.Put("/participants/{id}", handlers.UpdateParticipant)
 	r.Post("/participants/{id}/archive", handlers.ArchiveParticipant)
+	r.Get("/participants/{id}/decks", handlers.GetDecks)
+	r.Post("/participants/{id}/decks", handlers.CreateDeck)
+	r.Get("/participants/{id}/decks/{deckID}", handlers.GetDeck)
+	r.Put("/participants/{id}/decks/{deckID}", handlers.UpdateDeck)
+	r.Delete("/participants/{id}/decks/{deckID}", handlers.DeleteDeck)
 
 	r.Get("/tournaments", handlers.GetTournaments)
 	r.Post("/tournaments", handlers.CreateTournament)
 	r.Post("/tournaments/{id}/archive", handlers.ArchiveTournament)
+	r.Put("/tournaments/{id}/rules", handlers.UpdateTournamentRules)
 	r.Get("/tournaments/{id}", handlers.GetTournamentDetails)
+	r.Get("/tournaments/{id}/lifecycle", handlers.GetTournamentLifecycle)
 	r.Post("/tournaments/{id}/participants", handlers.AddParticipantToTournament)
+	r.Post("/tournaments/{id}/participants/{participantID}/withdraw", handlers.WithdrawParticipant)
 	r.Post("/tournaments/{id}/start", handlers.StartTournament) // Deprecated but kept
 	r.Post("/tournaments/{id}/groups", handlers.GenerateGroups)
 	r.Post("/tournaments/{id}/matches", handlers.GenerateMatches)
+	r.Get("/tournaments/{id}/standings", handlers.GetStandings)
+	r.Get("/tournaments/{id}/bracket", handlers.GetBracket)
+	r.Get("/tournaments/{id}/events", handlers.StreamTournamentEvents)
+	r.Post("/tournaments/{id}/tiebreaks", handlers.RecordTiebreak)
 	r.Post("/tournaments/{id}/advance", handlers.AdvanceTournamentPhase)
+	r.Post("/tournaments/{id}/rollback", handlers.RollbackTournament)
 	r.Post("/tournaments/{id}/reset", handlers.ResetTournament)
+	r.Get("/matches/{id}", handlers.GetMatch)
+	r.Get("/matches/{id}/scoreboard", handlers.GetMatchScoreboard)
+	r.Get("/matches/{id}/scoreboard/ws", handlers.MatchScoreboardSocket)
+	r.Get("/matches/{id}/overlay", handlers.MatchOverlay)
 	r.Post("/matches/{id}/score", handlers.UpdateMatchScore)
 	r.Post("/matches/{id}/reset", handlers.ResetMatch)
 	r.Post("/matches/{id}/manual", handlers.ManualMatchScore)
+	r.Post("/matches/{id}/undo", handlers.UndoMatchRound)
+	r.Post("/matches/{id}/time", handlers.CallTime)
+	r.Post("/matches/{id}/forfeit", handlers.ForfeitMatch)
 
 	fmt.Println("BBX Tournament App Backend Service Started on :8081")
 	if err := http.ListenAndServe(":8081", r); err != nil {
@@ -0,0 +1,22 @@
+package models
+
+import "gorm.io/gorm"
+
+// Live feed event types.
+const (
+	EventRoundScored      = "round_scored"
+	EventMatchDecided     = "match_decided"
+	EventMatchUpdated     = "match_updated" // Result cleared or changed by a reset, an undo or a bracket correction
+	EventPhaseAdvanced    = "phase_advanced"
+	EventStatusChanged    = "status_changed" // Any other action that moved the tournament to a new stage
+	EventParticipantAdded = "participant_added"
+)
+
+// TournamentEvent is an entry of a tournament's live feed, stored with the change it reports so
+// displays can replay what they missed. The ID is the stream's event ID.
+type TournamentEvent struct {
+	gorm.Model
+	TournamentID uint   `gorm:"index" json:"tournament_id"`
+	Type         string `json:"type"`
+	Data         string `json:"data"` // JSON payload
+}
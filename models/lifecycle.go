@@ -0,0 +1,117 @@
+package models
+
+import "gorm.io/gorm"
+
+// TournamentStatus is a stage of a tournament's lifecycle.
+type TournamentStatus string
+
+// Tournament lifecycle stages, in the order they are normally reached.
+const (
+	StatusCreated           TournamentStatus = "Created"           // Taking sign-ups
+	StatusGroupsGenerated   TournamentStatus = "GroupsGenerated"   // Groups or the Swiss pool drawn, no matches yet
+	StatusInProgress        TournamentStatus = "InProgress"        // Group or Swiss matches being played
+	StatusBracketInProgress TournamentStatus = "BracketInProgress" // Elimination bracket being played
+	StatusFinished          TournamentStatus = "Finished"          // Placements final
+)
+
+// TournamentAction is a request that depends on, and may change, the tournament's stage.
+type TournamentAction string
+
+// Actions of the tournament lifecycle.
+const (
+	ActionAddParticipant  TournamentAction = "AddParticipant"
+	ActionUpdateRules     TournamentAction = "UpdateRules"
+	ActionGenerateGroups  TournamentAction = "GenerateGroups"
+	ActionGenerateMatches TournamentAction = "GenerateMatches"
+	ActionRecordTiebreak  TournamentAction = "RecordTiebreak"
+	ActionWithdraw        TournamentAction = "Withdraw"
+	ActionScoreMatch      TournamentAction = "ScoreMatch" // Scoring, correcting, resetting or forfeiting a match
+	ActionAdvance         TournamentAction = "Advance"    // Next Swiss round, bracket seeding, next bracket round or finish
+	ActionRollback        TournamentAction = "Rollback"
+	ActionReset           TournamentAction = "Reset"
+	ActionCorrection      TournamentAction = "Correction" // A corrected bracket result left matches to replay
+)
+
+// Transition is an allowed move from one stage to another.
+type Transition struct {
+	From   TournamentStatus
+	Action TournamentAction
+	To     TournamentStatus
+}
+
+// Transitions lists every allowed move. An action may lead to different stages depending on
+// the tournament, e.g. advancing a bracket either opens the next round or finishes it.
+var Transitions = []Transition{
+	{StatusCreated, ActionAddParticipant, StatusCreated},
+
+	{StatusCreated, ActionUpdateRules, StatusCreated},
+	{StatusGroupsGenerated, ActionUpdateRules, StatusGroupsGenerated},
+
+	{StatusCreated, ActionGenerateGroups, StatusGroupsGenerated},
+	{StatusGroupsGenerated, ActionGenerateGroups, StatusGroupsGenerated},
+	{StatusGroupsGenerated, ActionGenerateMatches, StatusInProgress},
+
+	{StatusGroupsGenerated, ActionRecordTiebreak, StatusGroupsGenerated},
+	{StatusInProgress, ActionRecordTiebreak, StatusInProgress},
+
+	{StatusCreated, ActionWithdraw, StatusCreated},
+	{StatusGroupsGenerated, ActionWithdraw, StatusGroupsGenerated},
+	{StatusInProgress, ActionWithdraw, StatusInProgress},
+	{StatusBracketInProgress, ActionWithdraw, StatusBracketInProgress},
+
+	// Which matches can be scored at each stage is narrowed down by checkMatchScoring
+	{StatusInProgress, ActionScoreMatch, StatusInProgress},
+	{StatusBracketInProgress, ActionScoreMatch, StatusBracketInProgress},
+	{StatusFinished, ActionScoreMatch, StatusFinished},
+
+	{StatusInProgress, ActionAdvance, StatusInProgress},
+	{StatusInProgress, ActionAdvance, StatusBracketInProgress},
+	{StatusInProgress, ActionAdvance, StatusFinished},
+	{StatusBracketInProgress, ActionAdvance, StatusBracketInProgress},
+	{StatusBracketInProgress, ActionAdvance, StatusFinished},
+
+	{StatusBracketInProgress, ActionRollback, StatusBracketInProgress},
+	{StatusBracketInProgress, ActionRollback, StatusInProgress},
+	{StatusFinished, ActionRollback, StatusBracketInProgress},
+	{StatusFinished, ActionRollback, StatusInProgress},
+
+	{StatusFinished, ActionCorrection, StatusBracketInProgress},
+
+	{StatusCreated, ActionReset, StatusCreated},
+	{StatusGroupsGenerated, ActionReset, StatusCreated},
+	{StatusInProgress, ActionReset, StatusCreated},
+	{StatusBracketInProgress, ActionReset, StatusCreated},
+	{StatusFinished, ActionReset, StatusCreated},
+}
+
+// Actions returns the actions that can be taken from a stage, in table order.
+func (s TournamentStatus) Actions() []TournamentAction {
+	var actions []TournamentAction
+	seen := make(map[TournamentAction]bool)
+	for _, tr := range Transitions {
+		if tr.From == s && !seen[tr.Action] {
+			seen[tr.Action] = true
+			actions = append(actions, tr.Action)
+		}
+	}
+	return actions
+}
+
+// CanTransition reports whether action may move a tournament from one stage to another.
+func CanTransition(from TournamentStatus, action TournamentAction, to TournamentStatus) bool {
+	for _, tr := range Transitions {
+		if tr.From == from && tr.Action == action && tr.To == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TournamentTransition records an action taken on a tournament and the stage it led to.
+type TournamentTransition struct {
+	gorm.Model
+	TournamentID uint             `gorm:"index" json:"tournament_id"`
+	Action       TournamentAction `json:"action"`
+	From         TournamentStatus `json:"from"`
+	To           TournamentStatus `json:"to"`
+}
@@ -12,6 +12,8 @@ type Participant struct {
 	Nickname   string `gorm:"uniqueIndex;not null" json:"nickname"`
 	Avatar     string `json:"avatar"`
 	IsArchived bool   `gorm:"default:false" json:"is_archived"`
+	Club       string `json:"club"` // Store or team, club-mates are kept apart in group draws
+	Decks      []Deck `gorm:"foreignKey:ParticipantID" json:"decks,omitempty"`
 }
 
 // Deck represents a player's set of Beyblades for a match.
@@ -32,13 +34,24 @@ type Beyblade struct {
 	// Combined name could be helpful, e.g. "Dran Sword 3-60 Flat"
 }
 
+// Combo is the parts of a Beyblade as launched in a round. The round log keeps its own copy so
+// later deck edits do not rewrite history.
+type Combo struct {
+	Blade   string `json:"blade"`
+	Ratchet string `json:"ratchet"`
+	Bit     string `json:"bit"`
+}
+
 // TournamentParticipant represents the link between a tournament and a participant, including stats and group.
 type TournamentParticipant struct {
 	gorm.Model
 	TournamentID  uint        `json:"tournament_id"`
 	ParticipantID uint        `json:"participant_id"`
 	Participant   Participant `gorm:"foreignKey:ParticipantID" json:"participant"`
-	Group         string      `json:"group"` // "A", "B", etc.
+	Group         string      `json:"group"`   // "A", "B", etc.
+	Seed          int         `json:"seed"`    // Bracket seed, 0 if not qualified
+	DeckID        *uint       `json:"deck_id"` // Deck registered for this event, checked against Tournament.DeckRules
+	Deck          *Deck       `gorm:"foreignKey:DeckID" json:"deck,omitempty"`
 	Wins          int         `json:"wins"`
 	Losses        int         `json:"losses"`
 	Draws         int         `json:"draws"`
@@ -48,6 +61,68 @@ type TournamentParticipant struct {
 	BurstFinishes  int `json:"burst_finishes"`
 	OverFinishes   int `json:"over_finishes"`
 	XtremeFinishes int `json:"xtreme_finishes"`
+	// Swiss tiebreakers
+	Buchholz       int     `json:"buchholz"`         // Sum of opponents' points
+	OpponentWinPct float64 `json:"opponent_win_pct"` // Average opponent win rate, each floored at 1/3
+	// Round points across all matches, for the finish-point differential
+	ScoreFor     int `json:"score_for"`
+	ScoreAgainst int `json:"score_against"`
+	// Place within the group or Swiss pool, counting pool matches only
+	Rank          int    `json:"rank"`
+	RankDecidedBy string `json:"rank_decided_by"` // Criterion that split the player from the one ranked above, or below for first place
+	// Recorded result among tied players
+	TiebreakOrder  int    `json:"tiebreak_order"`  // 1 beat everyone else in the playoff, 0 if none recorded
+	TiebreakMethod string `json:"tiebreak_method"` // Playoff, CoinFlip
+	// Left the event: remaining matches are forfeited and the player cannot qualify
+	Withdrawn bool `json:"withdrawn"`
+	// Final standing once the tournament is finished, shared by players leaving in the same round
+	Placement int `json:"placement"`
+}
+
+// Deck rule sets a tournament can opt into.
+const (
+	DeckRulesStandard = "standard" // Official 3-on-3: three Beyblades, no repeated Blade, Ratchet or Bit
+	DeckRulesCasual   = "casual"   // One to three complete Beyblades, parts may repeat
+)
+
+// Stage formats played before the bracket.
+const (
+	FormatGroups = "Groups" // Round robin inside each group
+	FormatSwiss  = "Swiss"  // Everyone in one pool, paired by record each round
+)
+
+// Ways of drawing players into groups.
+const (
+	SeedingJoined  = "Joined"  // Dealt in sign-up order
+	SeedingRandom  = "Random"  // Shuffled with Tournament.DrawSeed
+	SeedingRating  = "Rating"  // Snake draft by league points across other events
+	SeedingResults = "Results" // Snake draft by standings in Tournament.SeedTournamentID
+	SeedingManual  = "Manual"  // Groups sent by the organizer
+)
+
+// Bracket formats a tournament can finish with.
+const (
+	BracketSingleElimination = "SingleElimination"
+	BracketDoubleElimination = "DoubleElimination"
+)
+
+// Match phases outside the group stage. Group matches use the group label as phase.
+const (
+	PhaseBracket        = "Bracket"        // Single elimination
+	PhaseWinnersBracket = "WinnersBracket" // Double elimination, no losses yet
+	PhaseLosersBracket  = "LosersBracket"  // Double elimination, one loss
+	PhaseGrandFinal     = "GrandFinal"     // Round 2 is the bracket reset
+	PhaseThirdPlace     = "ThirdPlace"     // Single elimination: semifinal losers, played alongside the final
+	PhaseSwiss          = "Swiss"          // Swiss rounds, also the group label of the single pool
+)
+
+// IsBracketPhase reports whether a match phase belongs to the elimination stage.
+func IsBracketPhase(phase string) bool {
+	switch phase {
+	case PhaseBracket, PhaseWinnersBracket, PhaseLosersBracket, PhaseGrandFinal, PhaseThirdPlace:
+		return true
+	}
+	return false
 }
 
 // Tournament represents a single event.
@@ -55,13 +130,141 @@ type Tournament struct {
 	gorm.Model
 	Name                   string                  `json:"name"`
 	Date                   time.Time               `json:"date"`
-	Status                 string                  `json:"status"` // Created, GroupsGenerated, InProgress, BracketInProgress, Finished
+	Status                 TournamentStatus        `json:"status"` // Created, GroupsGenerated, InProgress, BracketInProgress, Finished
 	IsArchived             bool                    `gorm:"default:false" json:"is_archived"`
+	DeckRules              string                  `json:"deck_rules"`                                      // standard, casual
+	Rules                  RuleSet                 `gorm:"embedded;embeddedPrefix:rule_" json:"rules"`      // Locked once matches exist
+	GroupSize              int                     `json:"group_size"`                                      // Target players per group, 0 for the default of 10
+	GroupCount             int                     `json:"group_count"`                                     // Fixed number of groups, overrides GroupSize
+	QualifiersPerGroup     int                     `json:"qualifiers_per_group"`                            // Players per group advancing to the bracket, 0 for 4
+	GroupSeeding           string                  `json:"group_seeding"`                                   // Joined, Random, Rating, Results, Manual
+	DrawSeed               int64                   `json:"draw_seed"`                                       // Random: shuffle seed, kept so the draw can be re-run
+	SeedTournamentID       uint                    `json:"seed_tournament_id"`                              // Results: event whose standings order the snake draft
+	Format                 string                  `json:"format"`                                          // Groups, Swiss: how the field is cut down before the bracket
+	SwissRounds            int                     `json:"swiss_rounds"`                                    // 0 picks enough rounds to leave one undefeated player
+	TopCut                 int                     `json:"top_cut"`                                         // Swiss players advancing to the bracket
+	BracketFormat          string                  `json:"bracket_format"`                                  // SingleElimination, DoubleElimination
+	BracketReset           bool                    `json:"bracket_reset"`                                   // Double elimination: replay the grand final if the losers bracket side wins it
+	ThirdPlaceMatch        bool                    `json:"third_place_match"`                               // Single elimination: semifinal losers play for third
 	Participants           []Participant           `gorm:"many2many:tournament_participants_old;" json:"-"` // Deprecated or kept for compat, prefer TournamentParticipants
 	TournamentParticipants []TournamentParticipant `gorm:"foreignKey:TournamentID" json:"tournament_participants"`
 	Matches                []Match                 `gorm:"foreignKey:TournamentID" json:"matches"`
 }
 
+// RuleSet holds the scoring rules of a tournament.
+type RuleSet struct {
+	// Points per finish type
+	SpinPoints   int `json:"spin_points"`
+	OverPoints   int `json:"over_points"`
+	BurstPoints  int `json:"burst_points"`
+	OutPoints    int `json:"out_points"`
+	XtremePoints int `json:"xtreme_points"`
+	// Points needed to take a match, per phase
+	GroupWinLimit   int `json:"group_win_limit"`
+	BracketWinLimit int `json:"bracket_win_limit"`
+	// Standings points
+	MatchWinPoints int `json:"match_win_points"`
+	DrawPoints     int `json:"draw_points"`
+	// Rounds after which a group or Swiss match ends on the scoreboard, 0 for no limit
+	GroupRoundLimit int `json:"group_round_limit"`
+	// Offences of one kind a player gets away with per match before each further one
+	// gives the opponent PenaltyPoints
+	PenaltyWarnings int `json:"penalty_warnings"`
+	PenaltyPoints   int `json:"penalty_points"`
+	// Group tiebreakers applied in order after points, empty for the default chain
+	Tiebreakers []string `gorm:"serializer:json" json:"tiebreakers"`
+}
+
+// Group tiebreakers. Points always rank first and a recorded playoff always last.
+const (
+	TiebreakPoints             = "Points"
+	TiebreakHeadToHead         = "HeadToHead"         // Standings points from matches among the tied players
+	TiebreakFinishDifferential = "FinishDifferential" // Round points scored minus conceded
+	TiebreakXtremeFinishes     = "XtremeFinishes"
+	TiebreakWins               = "Wins"
+	TiebreakPlayoff            = "Playoff" // Recorded playoff match or coin flip
+	TiebreakCoinFlip           = "CoinFlip"
+	TiebreakUnresolved         = "Unresolved" // Still tied, ordered by sign-up
+)
+
+// DefaultTiebreakers is the chain used when a rule set does not set one.
+func DefaultTiebreakers() []string {
+	return []string{TiebreakHeadToHead, TiebreakFinishDifferential, TiebreakXtremeFinishes, TiebreakPlayoff}
+}
+
+// TiebreakerChain returns the tiebreakers to apply after points, ending with the playoff.
+func (r RuleSet) TiebreakerChain() []string {
+	if len(r.Tiebreakers) == 0 {
+		return DefaultTiebreakers()
+	}
+	chain := append([]string{}, r.Tiebreakers...)
+	for _, name := range chain {
+		if name == TiebreakPlayoff {
+			return chain
+		}
+	}
+	return append(chain, TiebreakPlayoff)
+}
+
+// DefaultRuleSet returns the official rules: 7 points in groups, 10 in the bracket.
+func DefaultRuleSet() RuleSet {
+	return RuleSet{
+		SpinPoints:      1,
+		OverPoints:      2,
+		BurstPoints:     2,
+		OutPoints:       2,
+		XtremePoints:    3,
+		GroupWinLimit:   7,
+		BracketWinLimit: 10,
+		MatchWinPoints:  3,
+		DrawPoints:      1,
+		PenaltyWarnings: 1,
+		PenaltyPoints:   1,
+		Tiebreakers:     DefaultTiebreakers(),
+	}
+}
+
+// PointsFor returns the points a finish type is worth, false if the type is unknown.
+func (r RuleSet) PointsFor(winType string) (int, bool) {
+	switch winType {
+	case "Spin":
+		return r.SpinPoints, true
+	case "Over":
+		return r.OverPoints, true
+	case "Burst":
+		return r.BurstPoints, true
+	case "Out":
+		return r.OutPoints, true
+	case "Xtreme":
+		return r.XtremePoints, true
+	case WinTypeDraw:
+		return 0, true
+	}
+	return 0, false
+}
+
+// WinTypeDraw marks a round where both Beyblades stopped or burst together. It has no winner.
+const WinTypeDraw = "Draw"
+
+// WinTypePenalty marks a penalty entry in the round log. Its winner is the opponent of the
+// offender, or 0 while it is only a warning.
+const WinTypePenalty = "Penalty"
+
+// Penalties a judge can give.
+const (
+	PenaltyLaunchError       = "LaunchError"
+	PenaltyReadySetViolation = "ReadySetViolation"
+	PenaltyFoul              = "Foul"
+)
+
+// WinLimit returns the points needed to take a match in the given phase.
+func (r RuleSet) WinLimit(phase string) int {
+	if IsBracketPhase(phase) {
+		return r.BracketWinLimit
+	}
+	return r.GroupWinLimit
+}
+
 // Match represents a battle between two players.
 type Match struct {
 	gorm.Model
@@ -74,11 +277,64 @@ type Match struct {
 	ScoreP1  int   `json:"score_p1"`
 	ScoreP2  int   `json:"score_p2"`
 	WinnerID *uint `json:"winner_id"` // Nullable if draw/ongoing
+	// Group and Swiss play only: ended level once the round or time limit was reached
+	IsDraw     bool `json:"is_draw"`
+	TimeCalled bool `json:"time_called"` // Judge called time, the match ends on the current score
+	// Set when the result was not played out. WinnerID is the opponent of whoever forfeited,
+	// nil when both did.
+	Forfeit string `json:"forfeit,omitempty"` // Forfeit, Disqualification, Withdrawal
 
-	Phase string `json:"phase"` // Group, Bracket
-	Round int    `json:"round"` // Round number
+	Phase string `json:"phase"`  // Group label, Swiss, Bracket, ThirdPlace, WinnersBracket, LosersBracket, GrandFinal
+	Round int    `json:"round"`  // Round number
+	Slot  int    `json:"slot"`   // Position within the bracket round, top to bottom
+	IsBye bool   `json:"is_bye"` // Player1 advances without playing, Player2ID is 0
 
-	// Could store individual round results (spin/burst/extreme) as a JSON blob or separate table if detailed history is needed.
-	// For "start simple", maybe just scores? But tracking logic (Burst=2) might need logs.
-	// Let's add a serialized field for MatchLog if needed later.
+	// Bracket tree: the earlier bracket match each player comes from, 0 for a seeded slot.
+	// The slot takes that match's winner, or its loser when the FeederLoser flag is set.
+	Player1FeederID    uint `gorm:"index" json:"player1_feeder_id,omitempty"`
+	Player2FeederID    uint `gorm:"index" json:"player2_feeder_id,omitempty"`
+	Player1FeederLoser bool `json:"player1_feeder_loser,omitempty"`
+	Player2FeederLoser bool `json:"player2_feeder_loser,omitempty"`
+
+	// Rounds is the ordered log the scores were built from
+	Rounds []MatchRound `gorm:"foreignKey:MatchID" json:"rounds"`
+}
+
+// Ways a match can be given up instead of played out.
+const (
+	ForfeitNoShow       = "Forfeit" // No-show or concession
+	ForfeitDisqualified = "Disqualification"
+	ForfeitWithdrawal   = "Withdrawal" // The player left the event
+)
+
+// Decided reports whether the match has a result: a winner, a draw or a forfeit.
+func (m Match) Decided() bool {
+	return m.WinnerID != nil || m.IsDraw || m.Forfeit != ""
+}
+
+// WaitingForPlayers reports whether a slot is empty because the bracket match feeding it was
+// corrected and is undecided again.
+func (m Match) WaitingForPlayers() bool {
+	return m.Player1ID == 0 || (!m.IsBye && m.Player2ID == 0)
+}
+
+// MatchRound is a single scored round of a match, in the order it was played.
+type MatchRound struct {
+	gorm.Model
+	MatchID  uint   `gorm:"index" json:"match_id"`
+	Sequence int    `json:"sequence"`  // 1-based position in the match
+	WinnerID uint   `json:"winner_id"` // Player who took the round, 0 for a draw or a warning
+	WinType  string `json:"win_type"`  // Spin, Over, Burst, Out, Xtreme, Draw, Penalty
+	Points   int    `json:"points"`
+	// Penalty entries only
+	Penalty    string `json:"penalty,omitempty"`     // LaunchError, ReadySetViolation, Foul
+	OffenderID uint   `json:"offender_id,omitempty"` // Player who committed it
+	// Combos launched by each side, optional
+	Player1BeybladeID *uint     `json:"player1_beyblade_id"`
+	Player2BeybladeID *uint     `json:"player2_beyblade_id"`
+	Player1Beyblade   *Beyblade `gorm:"foreignKey:Player1BeybladeID" json:"player1_beyblade,omitempty"`
+	Player2Beyblade   *Beyblade `gorm:"foreignKey:Player2BeybladeID" json:"player2_beyblade,omitempty"`
+	// Parts of the combos as they were when the round was played
+	Player1Combo Combo `gorm:"embedded;embeddedPrefix:player1_" json:"player1_combo"`
+	Player2Combo Combo `gorm:"embedded;embeddedPrefix:player2_" json:"player2_combo"`
 }